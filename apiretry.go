@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// retriableAPIError returns true for errors the apiserver expects to be retried later, such as throttling by API
+// Priority and Fairness or an overloaded server.
+func retriableAPIError(err error) bool {
+	return errors.IsTooManyRequests(err) || errors.IsServerTimeout(err) || errors.IsTimeout(err) || errors.IsInternalError(err) ||
+		timedOut(err)
+}
+
+// retryAPI runs a Kubernetes API call, retrying transient failures with jittered exponential backoff so a large
+// fleet backs off together instead of hammering a struggling apiserver. Other errors are returned at once.
+func retryAPI(call string, fn func() error) error {
+	return withRetry(context.Background(), config.Kubernetes.Retry, retriableAPIError, "Kubernetes API call "+call, fn)
+}
+
+// kubernetesCall makes a Kubernetes API call with retries, observing every attempt.
+func kubernetesCall(call string, fn func() error) error {
+	return retryAPI(call, func() error {
+		started := time.Now()
+		err := fn()
+		observeCall("kubernetes", call, started, err)
+		return err
+	})
+}
+
+// retriableDockerError returns true for errors of the Docker daemon being unreachable or slow to answer, which a
+// later attempt may not hit. Errors the daemon answers with are final.
+func retriableDockerError(err error) bool {
+	return err == docker.ErrConnectionFailed || timedOut(err)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationApprovedRemovals on the Node lists the removals approved by someone holding the approval key, as
+// comma-separated <container ID>=<signature> pairs.
+const annotationApprovedRemovals = annotationPrefix + "approved-removals"
+
+// approvalRequired returns true if removals need an explicit approval.
+func approvalRequired() bool {
+	return config.Approval.Required || config.DanglingContainers.RequireApproval
+}
+
+// removalSignature returns the hex HMAC-SHA256 of <node>/<container ID> under the approval key. Binding the node
+// keeps an approval from being copied to another node's annotation.
+func removalSignature(key []byte, node, id string) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(node + "/" + id))
+
+	return hex.EncodeToString(mac.Sum(nil))
+
+}
+
+// signedApprovals returns the container IDs whose approval in the Node annotation carries a valid signature.
+func signedApprovals(node *v1.Node, key []byte) map[string]bool {
+
+	approved := map[string]bool{}
+
+	for _, entry := range strings.Split(node.Annotations[annotationApprovedRemovals], ",") {
+
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		expected := removalSignature(key, node.Name, parts[0])
+
+		if hmac.Equal([]byte(expected), []byte(strings.ToLower(parts[1]))) {
+			approved[parts[0]] = true
+		}
+
+	}
+
+	return approved
+
+}
+
+// approvalGuard defers every removal that has not been approved, either by setting the action of the container's
+// DanglingContainer to Remove or by a signed approval in the Node annotation. Approvals that cannot be read count
+// as missing.
+func approvalGuard(failures *cycleFailures) removalGuard {
+
+	var signed map[string]bool
+
+	if config.Approval.KeyFile != "" {
+
+		key, err := readSecret(config.Approval.KeyFile)
+
+		var node *v1.Node
+		if err == nil {
+			err = retryAPI("GetNode", func() (err error) {
+				started := time.Now()
+				node, err = kubeClient.CoreV1().Nodes().Get(nodeFlag, metav1.GetOptions{})
+				observeCall("kubernetes", "GetNode", started, err)
+				return err
+			})
+		}
+
+		if err != nil {
+			failures.add("approvals", err)
+		} else {
+			signed = signedApprovals(node, []byte(key))
+		}
+
+	}
+
+	return func(c types.Container, owner podOwner) string {
+
+		if signed[c.ID] {
+			return ""
+		}
+
+		if config.DanglingContainers.Namespace != "" {
+			if object, err := getDanglingContainer(c.ID); err == nil && requestedAction(object) == actionRemove {
+				return ""
+			}
+		}
+
+		return "awaiting approval"
+
+	}
+
+}
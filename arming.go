@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+)
+
+// Modes a node instance runs in.
+const (
+	modeWatch  = "watch"
+	modeRemove = "remove"
+)
+
+// armedFlag is the command-line arming of remove mode.
+var armedFlag bool
+
+// armRemoveMode settles the mode. Anything but a known mode falls back to watch, and remove mode also needs to be
+// armed, by the -i-understand-this-deletes-containers flag or armed in the configuration, so that a typo or a stray
+// MODE variable can never start deleting containers. Without a valid configuration there is no removing either.
+func armRemoveMode() {
+
+	switch modeFlag {
+	case modeWatch, modeNPD:
+		return
+	case modeRemove:
+		if configProblem != "" {
+			log.Println("Remove mode needs a valid configuration; running in watch mode.")
+			break
+		}
+		if armedFlag || config.Armed {
+			log.Println("Remove mode is armed: orphans will be stopped.")
+			return
+		}
+		log.Println("Remove mode is not armed; running in watch mode. Set armed: true or pass -i-understand-this-deletes-containers to remove.")
+	default:
+		log.Println("Unknown mode", modeFlag+"; running in watch mode.")
+	}
+
+	modeFlag = modeWatch
+
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/kernelpanek/dcc/pkg/checker"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// roleAuditor audits the nodes of the configured clusters from outside them, in report mode only.
+const roleAuditor = "auditor"
+
+// auditDockerAPIVersion is the Docker API version spoken to remote daemons.
+const auditDockerAPIVersion = "1.25"
+
+// auditDockerPort is the port of the nodes' Docker daemons unless docker_port says otherwise: Docker's TLS port.
+const auditDockerPort = 2376
+
+// auditedCluster is a configured cluster and its client.
+type auditedCluster struct {
+	dccconfig.Cluster
+	client kubernetes.Interface
+	docker *http.Client
+}
+
+// runAudit audits every node of every configured cluster each check interval. Findings are only reported, tagged
+// with the cluster they were found in; nothing is stopped. The audit does not start unless every cluster has the
+// TLS files to reach its Docker daemons with.
+func runAudit() {
+
+	var clusters []auditedCluster
+
+	for _, c := range config.Clusters {
+		if c.DockerCAFile == "" || c.DockerCertFile == "" || c.DockerKeyFile == "" {
+			log.Fatalln("Refusing to audit cluster", c.Name+": docker_ca_file, docker_cert_file and docker_key_file are required, as Docker daemons are only reached over TLS with a client certificate.")
+		}
+	}
+
+	for _, c := range config.Clusters {
+
+		cluster, err := newAuditedCluster(c)
+
+		if err != nil {
+			log.Println("Cannot audit cluster", c.Name+":", err.Error())
+			continue
+		}
+
+		clusters = append(clusters, cluster)
+
+	}
+
+	interval := time.Duration(config.Timing.CheckInterval) * time.Second
+
+	for {
+
+		heartbeat()
+
+		for _, cluster := range clusters {
+			auditCluster(cluster)
+		}
+
+		time.Sleep(interval)
+
+	}
+
+}
+
+// newAuditedCluster connects to a cluster through its kubeconfig and context, and prepares the client for the
+// Docker daemons of its nodes, which verifies them against the CA and authenticates with the client certificate.
+func newAuditedCluster(c dccconfig.Cluster) (auditedCluster, error) {
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.Kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: c.Context}).ClientConfig()
+
+	if err != nil {
+		return auditedCluster{}, err
+	}
+
+	restConfig.Timeout = time.Duration(config.Timeouts.Kubernetes) * time.Second
+
+	client, err := kubernetes.NewForConfig(restConfig)
+
+	if err != nil {
+		return auditedCluster{}, err
+	}
+
+	pem, err := ioutil.ReadFile(c.DockerCAFile)
+
+	if err != nil {
+		return auditedCluster{}, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return auditedCluster{}, fmt.Errorf("no certificates in %s", c.DockerCAFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.DockerCertFile, c.DockerKeyFile)
+
+	if err != nil {
+		return auditedCluster{}, err
+	}
+
+	if c.DockerPort == 0 {
+		c.DockerPort = auditDockerPort
+	}
+
+	tlsConfig := &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}}
+
+	return auditedCluster{
+		Cluster: c,
+		client:  client,
+		docker:  &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}, nil
+
+}
+
+// auditCluster audits each node of the cluster and notifies about its findings.
+func auditCluster(cluster auditedCluster) {
+
+	started := time.Now()
+	nodes, err := cluster.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	observeCall("kubernetes", "ListNodes", started, err)
+
+	if err != nil {
+		log.Println("Cannot list nodes of cluster", cluster.Name+":", err.Error())
+		return
+	}
+
+	for i := range nodes.Items {
+
+		node := &nodes.Items[i]
+		report := cycleReport{Cluster: cluster.Name, Node: node.Name, Mode: "watch", Time: time.Now()}
+
+		orphans, err := auditNode(cluster, node)
+
+		if err != nil {
+			log.Println("Cannot audit node", cluster.Name+"/"+node.Name+":", err.Error())
+			report.Failures = []string{"audit: " + err.Error()}
+		}
+
+		for _, c := range orphans {
+			owner := podOwner{Name: c.Labels[labelPodName], Namespace: c.Labels[labelPodNamespace], UID: c.Labels[labelPodUID], Container: c.Labels[labelContainerName], State: podStateUnknown}
+			report.Findings = append(report.Findings, newFinding(c, owner, ruleUnknownToKubernetes, outcomeReported, ""))
+		}
+
+		log.Println("Audited node", cluster.Name+"/"+node.Name+":", len(orphans), "dangling containers")
+		notify(report)
+
+	}
+
+}
+
+// auditNode compares the containers of the node's Docker daemon with the pods the cluster has scheduled there.
+func auditNode(cluster auditedCluster, node *v1.Node) ([]types.Container, error) {
+
+	address := ""
+
+	for _, a := range node.Status.Addresses {
+		if a.Type == v1.NodeInternalIP {
+			address = a.Address
+			break
+		}
+	}
+
+	if address == "" {
+		return nil, fmt.Errorf("node has no internal IP")
+	}
+
+	// The client speaks TLS as its transport is configured for it.
+	cli, err := docker.NewClient("tcp://"+net.JoinHostPort(address, strconv.Itoa(cluster.DockerPort)), auditDockerAPIVersion, cluster.docker, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dockerContext(context.Background(), 0)
+	defer cancel()
+
+	started := time.Now()
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	observeCall("docker", "ContainerList", started, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	started = time.Now()
+	pods, err := cluster.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+	})
+	observeCall("kubernetes", "ListPods", started, err)
+
+	// Without the pod list every container would look orphaned.
+	if err != nil {
+		return nil, err
+	}
+
+	var known []string
+
+	for i := range pods.Items {
+		known = append(known, kube.PodContainerIDs(&pods.Items[i])...)
+	}
+
+	return checker.Compare(containers, known, checkOptions()), nil
+
+}
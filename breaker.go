@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+var breakerStates = []breakerState{breakerClosed, breakerOpen, breakerHalfOpen}
+
+var (
+	breakerStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dcc",
+		Name:      "circuit_breaker_state",
+		Help:      "Current circuit breaker state; 1 for the active state, 0 otherwise.",
+	}, []string{"state"})
+
+	// breakerRetryGauge is computed at scrape time, so it counts down between state changes.
+	breakerRetryGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "dcc",
+		Name:      "circuit_breaker_retry_seconds",
+		Help:      "Seconds until an open circuit breaker is probed again; 0 when not open.",
+	}, func() float64 {
+		if state, retryAt := breaker.current(); state == breakerOpen && time.Now().Before(retryAt) {
+			return time.Until(retryAt).Seconds()
+		}
+		return 0
+	})
+
+	breakerTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "circuit_breaker_trips_total",
+		Help:      "Number of times the circuit breaker opened after repeated Docker or Kubernetes errors.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(breakerStateGauge, breakerRetryGauge, breakerTrips)
+	breaker.publish()
+}
+
+// circuitBreaker holds whether remediation is deliberately paused, why, and when it will next be retried.
+type circuitBreaker struct {
+	mutex   sync.Mutex
+	state   breakerState
+	reason  string
+	retryAt time.Time
+}
+
+// breakerStatus is the circuit breaker as reported on /status.
+type breakerStatus struct {
+	State   string    `json:"state"`
+	Reason  string    `json:"reason,omitempty"`
+	RetryAt time.Time `json:"retryAt,omitempty"`
+	RetryIn string    `json:"retryIn,omitempty"`
+}
+
+var breaker = &circuitBreaker{}
+
+// trip opens the breaker for the given reason until retryAt.
+func (b *circuitBreaker) trip(reason string, retryAt time.Time) {
+	b.set(breakerOpen, reason, retryAt)
+}
+
+// probe moves an open breaker to half-open so the next attempt decides whether it closes again.
+func (b *circuitBreaker) probe() {
+	b.mutex.Lock()
+	reason := b.reason
+	b.mutex.Unlock()
+	b.set(breakerHalfOpen, reason, time.Time{})
+}
+
+// reset closes the breaker.
+func (b *circuitBreaker) reset() {
+	b.set(breakerClosed, "", time.Time{})
+}
+
+func (b *circuitBreaker) set(state breakerState, reason string, retryAt time.Time) {
+
+	b.mutex.Lock()
+	b.state = state
+	b.reason = reason
+	b.retryAt = retryAt
+	b.mutex.Unlock()
+
+	b.publish()
+
+}
+
+// current returns the breaker state and, when open, when it is due to be probed.
+func (b *circuitBreaker) current() (breakerState, time.Time) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state, b.retryAt
+
+}
+
+// snapshot returns the breaker state for reporting.
+func (b *circuitBreaker) snapshot() breakerStatus {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := breakerStatus{State: b.state.String(), Reason: b.reason}
+
+	if b.state == breakerOpen {
+		s.RetryAt = b.retryAt
+		s.RetryIn = time.Until(b.retryAt).Round(time.Second).String()
+	}
+
+	return s
+
+}
+
+// publish updates the breaker state metric to match the current state.
+func (b *circuitBreaker) publish() {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, s := range breakerStates {
+		value := 0.0
+		if s == b.state {
+			value = 1
+		}
+		breakerStateGauge.WithLabelValues(s.String()).Set(value)
+	}
+
+}
+
+// errorCycles counts the consecutive cycles that failed to talk to Docker or Kubernetes.
+var errorCycles int
+
+// probeBreaker moves an open breaker whose retry time has come to half-open at the start of a cycle. The cycle still
+// removes nothing; if it completes without errors, the breaker closes for the next one.
+func probeBreaker() {
+
+	if state, retryAt := breaker.current(); state == breakerOpen && !time.Now().Before(retryAt) {
+		log.Println("Probing the circuit breaker.")
+		breaker.probe()
+	}
+
+}
+
+// updateBreaker counts the cycle's Docker and Kubernetes errors. After max_error_cycles consecutive cycles with
+// errors, or a failed probe, the breaker opens and removals stop until a probe succeeds probe_interval seconds later.
+func updateBreaker(failures *cycleFailures) {
+
+	limit := config.CircuitBreaker.MaxErrorCycles
+
+	if limit <= 0 {
+		return
+	}
+
+	// Too many orphans is a verdict on the data, and a broken filter plugin a local matter, not an error talking to
+	// Docker or Kubernetes.
+	var stages []string
+	for _, stage := range failures.failedStages() {
+		if stage != stageMassOrphans && stage != stageFilter {
+			stages = append(stages, stage)
+		}
+	}
+	sort.Strings(stages)
+
+	state, _ := breaker.current()
+
+	if len(stages) == 0 {
+		errorCycles = 0
+		if state == breakerHalfOpen {
+			log.Println("Circuit breaker closed, resuming removals.")
+			breaker.reset()
+			sendEvent("CircuitBreakerClosed", "Docker and Kubernetes respond again, removals resume")
+		}
+		return
+	}
+
+	errorCycles++
+
+	if state == breakerHalfOpen || state == breakerClosed && errorCycles >= limit {
+		reason := fmt.Sprintf("%d consecutive cycles failed: %s", errorCycles, strings.Join(stages, ", "))
+		breaker.trip(reason, time.Now().Add(time.Duration(config.CircuitBreaker.ProbeInterval)*time.Second))
+		breakerTrips.Inc()
+		log.Println("Circuit breaker opened, removals stop:", reason)
+		sendEvent("CircuitBreakerOpen", "Removals stopped after repeated errors, "+reason)
+	}
+
+}
+
+// breakerGuard holds every removal while the breaker is open or being probed.
+func breakerGuard(types.Container, podOwner) string {
+	s := breaker.snapshot()
+	return "circuit breaker " + s.State + ": " + s.Reason
+}
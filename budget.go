@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// maxBudgetPause caps a single pause, so a burst of CPU use never stalls the cycle for long.
+const maxBudgetPause = 5 * time.Second
+
+var throttledSeconds = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "throttled_seconds_total",
+	Help:      "Time dcc paused itself to stay within its CPU budget and Docker call pacing.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(throttledSeconds)
+}
+
+// cycleBudget paces the per-orphan work of a cycle, so the cleaner never becomes a noisy neighbour on a node that is
+// already struggling.
+type cycleBudget struct {
+	started   time.Time
+	cpuStart  time.Duration
+	lastPace  time.Time
+	throttled time.Duration
+}
+
+// newCycleBudget starts the budget of a cycle.
+func newCycleBudget() *cycleBudget {
+	return &cycleBudget{started: time.Now(), cpuStart: processCPUTime()}
+}
+
+// processCPUTime returns the user and system CPU time used by dcc so far.
+func processCPUTime() time.Duration {
+
+	var usage syscall.Rusage
+
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+
+}
+
+// pace waits before the Docker calls for the next orphan: at least call_interval milliseconds after the previous
+// orphan, and long enough to bring the CPU used since the cycle started back under max_cpu_percent of one core.
+func (b *cycleBudget) pace() {
+
+	var pause time.Duration
+
+	if interval := time.Duration(config.Budget.CallInterval) * time.Millisecond; !b.lastPace.IsZero() {
+		pause = interval - time.Since(b.lastPace)
+	}
+
+	if limit := config.Budget.MaxCPUPercent; limit > 0 {
+		used := processCPUTime() - b.cpuStart
+		if catchUp := used*100/time.Duration(limit) - time.Since(b.started); catchUp > pause {
+			pause = catchUp
+		}
+	}
+
+	if pause > maxBudgetPause {
+		pause = maxBudgetPause
+	}
+
+	if pause > 0 {
+		if b.throttled == 0 {
+			log.Println("Throttling cycle to stay within its resource budget.")
+		}
+		time.Sleep(pause)
+		b.throttled += pause
+		throttledSeconds.Add(pause.Seconds())
+	}
+
+	b.lastPace = time.Now()
+
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/kernelpanek/dcc/pkg/checker"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var unchangedCycles = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "unchanged_cycles_total",
+	Help:      "Number of check cycles cut short because the containers and pods were the same as in the last clean cycle.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(unchangedCycles)
+}
+
+// cycleSnapshot is what the last full cycle saw: the fingerprint of its container and pod ID sets, whether every
+// container was known to Kubernetes, and when it ran.
+type cycleSnapshot struct {
+	fingerprint string
+	clean       bool
+	at          time.Time
+}
+
+// lastFullCycle is the last full, unscoped cycle. It is only used from the check loop.
+var lastFullCycle cycleSnapshot
+
+// containerSetsFingerprint hashes the IDs of the containers on the node and of the containers Kubernetes knows, in
+// an order independent of how they were listed.
+func containerSetsFingerprint(containers []types.Container, known []string) string {
+
+	ids := make([]string, 0, len(containers))
+	for _, c := range containers {
+		ids = append(ids, checker.NormalizeID(c.ID))
+	}
+
+	knownIDs := make([]string, 0, len(known))
+	for _, id := range known {
+		knownIDs = append(knownIDs, checker.NormalizeID(id))
+	}
+
+	sort.Strings(ids)
+	sort.Strings(knownIDs)
+
+	hash := sha256.New()
+
+	for _, set := range [][]string{ids, knownIDs} {
+		for _, id := range set {
+			hash.Write([]byte(id))
+			hash.Write([]byte{'\n'})
+		}
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+
+}
+
+// allKnown returns true if Kubernetes knows every container on the node, so there is no orphan whatever the age of
+// the containers.
+func allKnown(containers []types.Container, known []string) bool {
+
+	set := newContainerIDSet(known)
+
+	for _, c := range containers {
+		if !set.Contains(c.ID) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// unchangedSinceLastCycle returns true if the cycle can be cut short with skip_unchanged set: the last full cycle
+// knew every container, and the containers and pods are exactly the same now, so this one would find nothing either.
+// A full cycle still runs at least every full_cycle_interval seconds, to refresh what it publishes.
+func unchangedSinceLastCycle(fingerprint string) bool {
+
+	if !config.Timing.SkipUnchanged || !lastFullCycle.clean || fingerprint != lastFullCycle.fingerprint {
+		return false
+	}
+
+	interval := time.Duration(config.Timing.FullCycleInterval) * time.Second
+
+	return interval <= 0 || time.Since(lastFullCycle.at) < interval
+
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+)
+
+// configPath is where the ConfigMap with config.yaml is mounted.
+const configPath = "/config/config.yaml"
+
+// configRetryInterval is how often a missing or invalid configuration is read again.
+const configRetryInterval = time.Minute
+
+// configProblem says why the configuration could not be loaded; dcc then runs report-only on built-in defaults.
+var configProblem string
+
+// reportConfigProblem records a warning event on the node when the configuration could not be loaded.
+func reportConfigProblem() {
+
+	if configProblem != "" {
+		sendEvent("ConfigInvalid", "Running report-only with built-in defaults, configuration cannot be loaded: "+configProblem)
+	}
+
+}
+
+// retryConfiguration reads a missing or invalid configuration again every minute. Once it loads, dcc shuts down
+// so that it is restarted with it, as everything set up at startup depends on the configuration.
+func retryConfiguration() {
+
+	if configProblem == "" {
+		return
+	}
+
+	for range time.Tick(configRetryInterval) {
+
+		if _, err := dccconfig.Read(configPath, config); err != nil {
+			continue
+		}
+
+		requestShutdown("Configuration can be loaded now, restarting to apply it")
+		return
+
+	}
+
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/kernelpanek/dcc/pkg/checker"
+)
+
+// Container ID matching modes. Exact compares full IDs. Prefix also accepts an ID that is a prefix of the other, for
+// pod statuses or tools that report IDs truncated to the Docker CLI's short form.
+const (
+	idMatchExact  = "exact"
+	idMatchPrefix = "prefix"
+)
+
+// newContainerIDSet returns the set of the given IDs, matched as configured by id_matching.
+func newContainerIDSet(ids []string) checker.IDSet {
+	return checker.NewIDSet(ids, config.Kubernetes.IDMatching == idMatchPrefix)
+}
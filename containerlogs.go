@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// defaultLogTailBytes caps the log tail attached to a finding when max_bytes is not configured.
+const defaultLogTailBytes = 4096
+
+// containerLogTail returns the last lines of a container's stdout and stderr, capped at the configured size. An
+// empty string is returned when log capture is disabled or the logs cannot be read.
+func containerLogTail(ctx context.Context, cli *docker.Client, id string) string {
+
+	lines := config.Notifications.LogTail.Lines
+
+	if lines <= 0 || cli == nil {
+		return ""
+	}
+
+	maxBytes := config.Notifications.LogTail.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultLogTailBytes
+	}
+
+	// The deadline also covers reading the stream below.
+	ctx, cancel := dockerContext(ctx, 0)
+	defer cancel()
+
+	started := time.Now()
+	reader, err := cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	observeCall("docker", "ContainerLogs", started, err)
+
+	if err != nil {
+		log.Println("Cannot read logs of container", id+":", err.Error())
+		return ""
+	}
+
+	defer reader.Close()
+
+	logTail, err := readLogTail(reader, maxBytes)
+
+	if err != nil {
+		log.Println("Cannot read logs of container", id+":", err.Error())
+		return ""
+	}
+
+	return logTail
+
+}
+
+// readLogTail returns the last maxBytes of a log stream. The whole stream is read, so that frames are demultiplexed
+// intact, but only its last bytes are kept.
+func readLogTail(reader io.Reader, maxBytes int) (string, error) {
+
+	tail := &tailBuffer{max: maxBytes}
+	buffered := bufio.NewReader(reader)
+
+	// Containers without a TTY multiplex stdout and stderr; TTY containers return the raw stream.
+	var err error
+	if multiplexed(buffered) {
+		_, err = stdcopy.StdCopy(tail, tail, buffered)
+	} else {
+		_, err = io.Copy(tail, buffered)
+	}
+
+	return string(tail.Bytes()), err
+
+}
+
+// multiplexed returns true if the stream starts with the header of a stdout or stderr frame.
+func multiplexed(r *bufio.Reader) bool {
+
+	header, err := r.Peek(8)
+
+	return err == nil && header[0] <= 2 && header[1] == 0 && header[2] == 0 && header[3] == 0
+
+}
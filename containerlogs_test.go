@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestReadLogTail(t *testing.T) {
+
+	var multiplexedStream bytes.Buffer
+	stdout := stdcopy.NewStdWriter(&multiplexedStream, stdcopy.Stdout)
+	stderr := stdcopy.NewStdWriter(&multiplexedStream, stdcopy.Stderr)
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		line := strings.Repeat(string(rune('a'+i%26)), 9) + "\n"
+		lines = append(lines, line)
+		if i%2 == 0 {
+			stdout.Write([]byte(line))
+		} else {
+			stderr.Write([]byte(line))
+		}
+	}
+
+	want := strings.Join(lines, "")[1000-64:]
+
+	tests := []struct {
+		name   string
+		stream []byte
+	}{
+		{"multiplexed", multiplexedStream.Bytes()},
+		{"tty", []byte(strings.Join(lines, ""))},
+	}
+
+	for _, test := range tests {
+
+		got, err := readLogTail(bytes.NewReader(test.stream), 64)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		if got != want {
+			t.Errorf("%s: tail = %q, want %q", test.name, got, want)
+		}
+
+	}
+
+}
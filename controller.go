@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Roles a dcc process can take. A standalone process does everything on its own node; agents collect and act on their
+// node while deferring policy and reporting to a central controller.
+const (
+	roleStandalone = "standalone"
+	roleAgent      = "agent"
+	roleController = "controller"
+)
+
+var controllerDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "controller_decisions_total",
+	Help:      "Number of removal requests answered by the controller, by decision.",
+}, []string{"decision"})
+
+func init() {
+	metrics.Registry.MustRegister(controllerDecisions)
+}
+
+// controller applies removal policy for all agents and aggregates their reports.
+type controller struct {
+	mutex        sync.Mutex
+	slots        []removalSlot
+	reservations uint64
+	reports      map[string]cycleReport
+}
+
+// removalSlot is a share of the cluster-wide removal budget. An authorized removal reserves one until the agent
+// confirms the stop, which then counts for a minute, or releases it because the stop did not happen. A reservation
+// the agent never settles expires after reservation_timeout.
+type removalSlot struct {
+	id        string
+	at        time.Time
+	confirmed bool
+}
+
+// recentRemovals returns the number of slots in use: stops confirmed within the last minute and reservations not yet
+// expired. The others are forgotten. The mutex must be held.
+func (c *controller) recentRemovals(now time.Time) int {
+
+	timeout := time.Duration(config.Controller.ReservationTimeout) * time.Second
+	slots := c.slots[:0]
+
+	for _, s := range c.slots {
+		if (s.confirmed && now.Sub(s.at) < time.Minute) || (!s.confirmed && now.Sub(s.at) < timeout) {
+			slots = append(slots, s)
+		}
+	}
+
+	c.slots = slots
+
+	return len(c.slots)
+
+}
+
+// Authorize allows a removal when the controller runs in remove mode and fewer than max_removals_per_minute slots are
+// in use, and reserves one for it. Counting and reserving happen under one lock, so agents asking at the same time
+// cannot overrun the limit.
+func (c *controller) Authorize(ctx context.Context, request *authorizeRequest) (*authorizeResponse, error) {
+
+	if err := verifyPeerNode(ctx, request.Node); err != nil {
+		return nil, err
+	}
+
+	response := &authorizeResponse{Allowed: true}
+	limit := config.Controller.MaxRemovalsPerMinute
+
+	c.mutex.Lock()
+
+	switch {
+	case modeFlag != "remove":
+		response = &authorizeResponse{Reason: "controller is in " + modeFlag + " mode"}
+	case limit > 0 && c.recentRemovals(time.Now()) >= limit:
+		response = &authorizeResponse{Reason: "cluster-wide removal rate limit reached"}
+	case limit > 0:
+		c.reservations++
+		response.Reservation = fmt.Sprintf("%s/%d", request.Node, c.reservations)
+		c.slots = append(c.slots, removalSlot{id: response.Reservation, at: time.Now()})
+	}
+
+	c.mutex.Unlock()
+
+	if response.Allowed {
+		controllerDecisions.WithLabelValues("allowed").Inc()
+	} else {
+		controllerDecisions.WithLabelValues("denied").Inc()
+	}
+
+	return response, nil
+
+}
+
+// Confirm turns the reservation of a stop an agent carried out into a confirmed stop. A stop whose reservation has
+// already expired is still charged to the budget.
+func (c *controller) Confirm(ctx context.Context, request *settleRequest) (*reportAck, error) {
+
+	if err := verifyPeerNode(ctx, request.Node); err != nil {
+		return nil, err
+	}
+
+	if request.Reservation == "" {
+		return &reportAck{}, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, s := range c.slots {
+		if s.id == request.Reservation && !s.confirmed {
+			c.slots[i] = removalSlot{id: s.id, at: time.Now(), confirmed: true}
+			return &reportAck{}, nil
+		}
+	}
+
+	c.slots = append(c.slots, removalSlot{id: request.Reservation, at: time.Now(), confirmed: true})
+
+	return &reportAck{}, nil
+
+}
+
+// Release frees the reservation of a removal the agent did not carry out: deferred, already down, skipped or failed.
+func (c *controller) Release(ctx context.Context, request *settleRequest) (*reportAck, error) {
+
+	if err := verifyPeerNode(ctx, request.Node); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, s := range c.slots {
+		if s.id == request.Reservation && !s.confirmed {
+			c.slots = append(c.slots[:i], c.slots[i+1:]...)
+			break
+		}
+	}
+
+	return &reportAck{}, nil
+
+}
+
+// Report stores the latest report of an agent and hands it to the controller's notifiers. The report is filed under
+// the node its sender's certificate names, so an agent cannot report for another node.
+func (c *controller) Report(ctx context.Context, report *cycleReport) (*reportAck, error) {
+
+	if err := verifyPeerNode(ctx, report.Node); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.reports[report.Node] = *report
+	c.mutex.Unlock()
+
+	notify(*report)
+
+	return &reportAck{}, nil
+
+}
+
+// verifyPeerNode checks that the verified client certificate of the call names the given node, as its common name or
+// one of its DNS names.
+func verifyPeerNode(ctx context.Context, node string) error {
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return grpcstatus.Error(codes.Unauthenticated, "no peer")
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return grpcstatus.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+
+	if node != "" && cert.Subject.CommonName == node {
+		return nil
+	}
+
+	for _, name := range cert.DNSNames {
+		if node != "" && name == node {
+			return nil
+		}
+	}
+
+	return grpcstatus.Errorf(codes.PermissionDenied, "client certificate %q does not name node %q", cert.Subject.CommonName, node)
+
+}
+
+// serveReports writes the latest report of every agent as JSON.
+func (c *controller) serveReports(w http.ResponseWriter, r *http.Request) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.reports)
+
+}
+
+// runController serves the controller protocol until the listener fails.
+func runController() {
+
+	c := &controller{reports: map[string]cycleReport{}}
+
+	tlsConfig, err := controllerTLS()
+
+	if err != nil {
+		log.Fatalln("Cannot serve agents:", err.Error())
+	}
+
+	http.HandleFunc("/reports", c.serveReports)
+
+	listener, err := net.Listen("tcp", config.Controller.Listen)
+
+	if err != nil {
+		log.Fatalln("Cannot listen for agents:", err.Error())
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)), grpc.CustomCodec(jsonCodec{}))
+	server.RegisterService(&controllerServiceDesc, c)
+
+	log.Println("Serving agents on", config.Controller.Listen)
+
+	if err := server.Serve(listener); err != nil {
+		log.Fatalln("Controller listener stopped:", err.Error())
+	}
+
+}
+
+// controllerReservations holds the budget reservation the controller made for each authorized orphan, until the agent
+// confirms or releases it.
+var controllerReservations = struct {
+	sync.Mutex
+	byContainer map[string]string
+}{byContainer: map[string]string{}}
+
+// controllerGuard defers removals the controller does not authorize. An unreachable controller defers everything,
+// since agents hold no policy of their own.
+func controllerGuard() removalGuard {
+
+	return func(c types.Container, owner podOwner) string {
+
+		request := authorizeRequest{Node: nodeFlag, Finding: newFinding(c, owner, ruleUnknownToKubernetes, "", "")}
+		var response authorizeResponse
+
+		if err := callController(rpcAuthorize, &request, &response); err != nil {
+			return fmt.Sprintf("controller unavailable: %s", err.Error())
+		}
+
+		if !response.Allowed {
+			return response.Reason
+		}
+
+		if response.Reservation != "" {
+			controllerReservations.Lock()
+			controllerReservations.byContainer[c.ID] = response.Reservation
+			controllerReservations.Unlock()
+		}
+
+		return ""
+
+	}
+
+}
+
+// takeReservation removes and returns the controller's reservation for a container, if it has one.
+func takeReservation(id string) (string, bool) {
+
+	controllerReservations.Lock()
+	defer controllerReservations.Unlock()
+
+	reservation, ok := controllerReservations.byContainer[id]
+	delete(controllerReservations.byContainer, id)
+
+	return reservation, ok
+
+}
+
+// confirmToController tells the controller that an orphan it authorized was stopped, so that the stop counts against
+// the cluster-wide removal budget.
+func confirmToController(f finding) {
+
+	reservation, ok := takeReservation(f.ContainerID)
+	if !ok {
+		return
+	}
+
+	if err := callController(rpcConfirm, &settleRequest{Node: nodeFlag, Reservation: reservation, Finding: f}, &reportAck{}); err != nil {
+		log.Println("Cannot confirm stop to controller:", err.Error())
+	}
+
+}
+
+// releaseToController gives back the budget the controller reserved for an orphan that was not stopped after all.
+// An unreachable controller lets the reservation expire instead.
+func releaseToController(id string) {
+
+	reservation, ok := takeReservation(id)
+	if !ok {
+		return
+	}
+
+	if err := callController(rpcRelease, &settleRequest{Node: nodeFlag, Reservation: reservation}, &reportAck{}); err != nil {
+		log.Println("Cannot release removal to controller:", err.Error())
+	}
+
+}
+
+// reportToController sends the cycle report to the controller.
+func reportToController(report cycleReport) {
+
+	if err := callController(rpcReport, &report, &reportAck{}); err != nil {
+		log.Println("Cannot send report to controller:", err.Error())
+	}
+
+}
+
+// publishReport hands the cycle report to the notification sinks, or to the controller that owns them for agents.
+func publishReport(report cycleReport) {
+
+	if roleFlag == roleAgent {
+		reportToController(report)
+		return
+	}
+
+	notify(report)
+
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// agentContext returns the context of a call from an agent whose verified certificate has the given common name.
+func agentContext(commonName string) context.Context {
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+
+}
+
+// useControllerConfig sets the controller's removal limit for a test and returns a function restoring the previous
+// configuration.
+func useControllerConfig(limit int) func() {
+
+	previousConfig, previousMode := config, modeFlag
+
+	config.Controller.MaxRemovalsPerMinute = limit
+	config.Controller.ReservationTimeout = 300
+	modeFlag = modeRemove
+
+	return func() {
+		config, modeFlag = previousConfig, previousMode
+	}
+
+}
+
+func TestAuthorizeReservesUnderConcurrency(t *testing.T) {
+
+	defer useControllerConfig(5)()
+
+	c := &controller{reports: map[string]cycleReport{}}
+	ctx := agentContext("node-a")
+
+	var wg sync.WaitGroup
+	responses := make(chan *authorizeResponse, 50)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := c.Authorize(ctx, &authorizeRequest{Node: "node-a"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			responses <- response
+		}()
+	}
+
+	wg.Wait()
+	close(responses)
+
+	var reservations []string
+
+	for response := range responses {
+		if response.Allowed {
+			reservations = append(reservations, response.Reservation)
+		}
+	}
+
+	if len(reservations) != 5 {
+		t.Fatalf("%d removals allowed, want 5", len(reservations))
+	}
+
+	// A released reservation frees its slot; a confirmed one keeps it.
+	c.Release(ctx, &settleRequest{Node: "node-a", Reservation: reservations[0]})
+	c.Confirm(ctx, &settleRequest{Node: "node-a", Reservation: reservations[1]})
+
+	if response, _ := c.Authorize(ctx, &authorizeRequest{Node: "node-a"}); !response.Allowed {
+		t.Error("removal denied after a reservation was released")
+	}
+
+	if response, _ := c.Authorize(ctx, &authorizeRequest{Node: "node-a"}); response.Allowed {
+		t.Error("removal allowed beyond the limit after a reservation was confirmed")
+	}
+
+}
+
+func TestAuthorizeForgetsExpiredReservations(t *testing.T) {
+
+	defer useControllerConfig(1)()
+
+	c := &controller{reports: map[string]cycleReport{}}
+	ctx := agentContext("node-a")
+
+	if response, _ := c.Authorize(ctx, &authorizeRequest{Node: "node-a"}); !response.Allowed {
+		t.Fatal("first removal denied")
+	}
+
+	c.slots[0].at = time.Now().Add(-301 * time.Second)
+
+	if response, _ := c.Authorize(ctx, &authorizeRequest{Node: "node-a"}); !response.Allowed {
+		t.Error("removal denied after the only reservation expired")
+	}
+
+}
+
+func TestControllerRejectsOtherNodes(t *testing.T) {
+
+	defer useControllerConfig(0)()
+
+	c := &controller{reports: map[string]cycleReport{}}
+	ctx := agentContext("node-a")
+
+	if _, err := c.Report(ctx, &cycleReport{Node: "node-b"}); err == nil {
+		t.Error("report for another node accepted")
+	}
+
+	if _, err := c.Authorize(ctx, &authorizeRequest{Node: "node-b"}); err == nil {
+		t.Error("authorization for another node accepted")
+	}
+
+	if _, err := c.Authorize(context.Background(), &authorizeRequest{Node: "node-a"}); err == nil {
+		t.Error("authorization without a client certificate accepted")
+	}
+
+	if len(c.reports) != 0 {
+		t.Errorf("rejected report stored: %v", c.reports)
+	}
+
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// stopCooldown is a container whose stops keep failing and when it may be tried again.
+type stopCooldown struct {
+	failures int
+	until    time.Time
+}
+
+// stopCooldowns holds the containers on cooldown by ID. It is only used from the check loop.
+var stopCooldowns = map[string]*stopCooldown{}
+
+// cooldownGuard defers containers on cooldown after failed stops, so a container that cannot be stopped is not
+// hammered, and reported, every cycle.
+func cooldownGuard(c types.Container, owner podOwner) string {
+
+	cd, ok := stopCooldowns[c.ID]
+
+	if !ok || !time.Now().Before(cd.until) {
+		return ""
+	}
+
+	return fmt.Sprintf("%d failed stops, next attempt in %s", cd.failures, time.Until(cd.until).Round(time.Second))
+
+}
+
+// recordStopFailure puts the container on cooldown after a failed stop. The cooldown starts at initial_delay and
+// doubles with every further failure up to max_delay. It returns true once the failures reach escalate_after, which
+// happens only once per container.
+func recordStopFailure(id string) bool {
+
+	cfg := config.Timing.StopCooldown
+
+	cd, ok := stopCooldowns[id]
+	if !ok {
+		cd = &stopCooldown{}
+		stopCooldowns[id] = cd
+	}
+
+	cd.failures++
+
+	delay := time.Duration(cfg.InitialDelay) * time.Second
+	maxDelay := time.Duration(cfg.MaxDelay) * time.Second
+
+	for i := 1; i < cd.failures && delay > 0 && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	cd.until = time.Now().Add(delay)
+	log.Println("Container", id, "failed to stop", cd.failures, "times, next attempt in", delay)
+
+	return cfg.EscalateAfter > 0 && cd.failures == cfg.EscalateAfter
+
+}
+
+// clearStopCooldown forgets the failures of a container that is no longer running.
+func clearStopCooldown(id string) {
+	delete(stopCooldowns, id)
+}
+
+// pruneStopCooldowns forgets containers that are no longer orphans. It must only be given the orphans of a check of
+// the whole node, as a namespace-scoped check would forget the failures of every container outside its namespaces.
+func pruneStopCooldowns(orphans []types.Container) {
+
+	current := make(map[string]bool, len(orphans))
+
+	for _, c := range orphans {
+		current[c.ID] = true
+	}
+
+	for id := range stopCooldowns {
+		if !current[id] {
+			delete(stopCooldowns, id)
+		}
+	}
+
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Custom resources dcc writes are served under this API group and version.
+const (
+	crdGroup   = "dcc.kernelpanek.io"
+	crdVersion = "v1alpha1"
+)
+
+// customResource is a custom object handled as plain JSON, since dcc has no generated clients for its resources.
+type customResource map[string]interface{}
+
+// customResourcePath returns the API path of a namespaced custom resource, or of its collection when name is empty.
+func customResourcePath(plural, namespace, name string) string {
+
+	path := fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", crdGroup, crdVersion, namespace, plural)
+
+	if name != "" {
+		path += "/" + name
+	}
+
+	return path
+
+}
+
+// getCustomResource fetches a custom resource.
+func getCustomResource(plural, namespace, name string) (customResource, error) {
+
+	raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(customResourcePath(plural, namespace, name)).Do().Raw()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var object customResource
+	return object, json.Unmarshal(raw, &object)
+
+}
+
+// saveCustomResource creates the resource or, if it exists, replaces it while keeping its resourceVersion.
+func saveCustomResource(plural, namespace, name string, object customResource) error {
+
+	existing, err := getCustomResource(plural, namespace, name)
+
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	metadata, _ := object["metadata"].(map[string]interface{})
+
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		object["metadata"] = metadata
+	}
+
+	metadata["name"] = name
+	metadata["namespace"] = namespace
+
+	body, err := json.Marshal(object)
+
+	if err != nil {
+		return err
+	}
+
+	client := kubeClient.CoreV1().RESTClient()
+
+	if existing == nil {
+		return client.Post().AbsPath(customResourcePath(plural, namespace, "")).
+			SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+	}
+
+	if existingMetadata, ok := existing["metadata"].(map[string]interface{}); ok {
+		metadata["resourceVersion"] = existingMetadata["resourceVersion"]
+		if body, err = json.Marshal(object); err != nil {
+			return err
+		}
+	}
+
+	return client.Put().AbsPath(customResourcePath(plural, namespace, name)).
+		SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+
+}
+
+// writeReportResource records the cycle in the node's DanglingContainerReport.
+func writeReportResource(report cycleReport) {
+
+	namespace := config.Reports.CRD.Namespace
+
+	if namespace == "" {
+		return
+	}
+
+	findings := report.Findings
+	if findings == nil {
+		findings = []finding{}
+	}
+
+	object := customResource{
+		"apiVersion": crdGroup + "/" + crdVersion,
+		"kind":       "DanglingContainerReport",
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{"app": "dcc", "dcc.kernelpanek.io/node": report.Node},
+		},
+		"spec": map[string]interface{}{
+			"node": report.Node,
+		},
+		"status": map[string]interface{}{
+			"mode":      report.Mode,
+			"cycleTime": report.Time,
+			"found":     report.Found(),
+			"removed":   report.Removed(),
+			"findings":  findings,
+		},
+	}
+
+	if err := saveCustomResource("danglingcontainerreports", namespace, report.Node, object); err != nil {
+		log.Println("Cannot write DanglingContainerReport:", err.Error())
+	}
+
+}
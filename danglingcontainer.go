@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Phases of a DanglingContainer resource.
+const (
+	phaseDetected  = "Detected"
+	phaseConfirmed = "Confirmed"
+	phaseRemoved   = "Removed"
+	phaseFailed    = "Failed"
+	phaseIgnored   = "Ignored"
+)
+
+// Actions a person or another controller can request in a DanglingContainer's spec.
+const (
+	actionRemove = "Remove"
+	actionIgnore = "Ignore"
+)
+
+// danglingContainerName returns the name of the resource tracking a container on this node.
+func danglingContainerName(id string) string {
+	return fmt.Sprintf("%s-%.12s", nodeFlag, id)
+}
+
+// getDanglingContainer fetches the resource tracking a container. A missing resource is returned as nil.
+func getDanglingContainer(id string) (customResource, error) {
+
+	object, err := getCustomResource("danglingcontainers", config.DanglingContainers.Namespace, danglingContainerName(id))
+
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	return object, err
+
+}
+
+// requestedAction returns the action requested in the resource's spec, if any.
+func requestedAction(object customResource) string {
+
+	spec, _ := object["spec"].(map[string]interface{})
+	action, _ := spec["action"].(string)
+
+	return action
+
+}
+
+// danglingContainerGuard defers removals that were ignored in the container's resource. When the resource cannot be
+// read the removal is deferred, since it may carry an Ignore.
+func danglingContainerGuard() removalGuard {
+
+	return func(c types.Container, owner podOwner) string {
+
+		object, err := getDanglingContainer(c.ID)
+
+		if err != nil {
+			return "cannot read DanglingContainer: " + err.Error()
+		}
+
+		if requestedAction(object) == actionIgnore {
+			return "ignored in DanglingContainer " + danglingContainerName(c.ID)
+		}
+
+		return ""
+
+	}
+
+}
+
+// recordDanglingContainer creates or updates the resource tracking an orphan with the outcome of this cycle. The spec
+// fields set by people, and any labels or annotations they added, are kept.
+func recordDanglingContainer(f finding, stopErr error) {
+
+	namespace := config.DanglingContainers.Namespace
+
+	if namespace == "" {
+		return
+	}
+
+	object, err := getDanglingContainer(f.ContainerID)
+
+	if err != nil {
+		log.Println("Cannot read DanglingContainer:", err.Error())
+		return
+	}
+
+	if object == nil {
+		object = customResource{
+			"apiVersion": crdGroup + "/" + crdVersion,
+			"kind":       "DanglingContainer",
+			"metadata": map[string]interface{}{
+				"labels": map[string]string{"app": "dcc", "dcc.kernelpanek.io/node": nodeFlag},
+			},
+			"spec":   map[string]interface{}{},
+			"status": map[string]interface{}{"firstSeen": time.Now()},
+		}
+	}
+
+	spec, _ := object["spec"].(map[string]interface{})
+	if spec == nil {
+		spec = map[string]interface{}{}
+		object["spec"] = spec
+	}
+
+	spec["node"] = nodeFlag
+	spec["containerID"] = f.ContainerID
+	spec["image"] = f.Image
+	spec["pod"] = f.Pod
+	spec["podUID"] = f.PodUID
+
+	status, _ := object["status"].(map[string]interface{})
+	if status == nil {
+		status = map[string]interface{}{"firstSeen": time.Now()}
+		object["status"] = status
+	}
+
+	previous, _ := status["phase"].(string)
+	phase := phaseDetected
+	message := ""
+
+	switch {
+	case stopErr != nil:
+		phase = phaseFailed
+		message = stopErr.Error()
+	case f.Outcome == outcomeStopped:
+		phase = phaseRemoved
+	case requestedAction(object) == actionIgnore:
+		phase = phaseIgnored
+	case previous != "" || sightings[f.ContainerID] != nil && sightings[f.ContainerID].Count > 1:
+		phase = phaseConfirmed
+	}
+
+	status["phase"] = phase
+	status["outcome"] = f.Outcome
+	status["reason"] = f.Reason
+	status["message"] = message
+	status["lastSeen"] = time.Now()
+
+	if err := saveCustomResource("danglingcontainers", namespace, danglingContainerName(f.ContainerID), object); err != nil {
+		log.Println("Cannot write DanglingContainer:", err.Error())
+	}
+
+}
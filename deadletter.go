@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// sendWithRetry hands the report to the sink, retrying failures with exponential backoff.
+func sendWithRetry(s sink, report cycleReport) error {
+	return withRetry(context.Background(), config.Notifications.Retry, retryAlways, "Notification to "+s.id, func() error {
+		return s.Notify(report)
+	})
+}
+
+// notificationQueueSize bounds the reports waiting for each sink. Sinks are slow to fail, with retries on top of the
+// HTTP timeout, so each is sent to from its own goroutine; a sink that is down holds up only its own queue, and
+// reports that do not fit are spooled rather than holding up the cycle.
+const notificationQueueSize = 16
+
+// delivery is a report queued for a sink. Quiet reports only replay dead letters and resolve alerts.
+type delivery struct {
+	report cycleReport
+	quiet  bool
+}
+
+// sinkQueue holds the reports waiting for a sink until its goroutine delivers them.
+type sinkQueue struct {
+	mutex      sync.Mutex
+	deliveries chan delivery
+	closed     bool
+	done       chan struct{}
+}
+
+func newSinkQueue() *sinkQueue {
+	return &sinkQueue{deliveries: make(chan delivery, notificationQueueSize), done: make(chan struct{})}
+}
+
+// run delivers the sink's queued reports in order until the queue is closed and drained.
+func (s sink) run() {
+
+	defer close(s.queue.done)
+
+	for d := range s.queue.deliveries {
+		if d.quiet {
+			resolveSink(s, d.report)
+		} else {
+			deliverToSink(s, d.report)
+		}
+	}
+
+}
+
+// enqueue queues a report for the sink without blocking. Reports that do not fit, or arrive after shutdown, are
+// spooled; quiet reports are dropped, as the next quiet cycle resolves alerts all the same.
+func (s sink) enqueue(d delivery) {
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	if !s.queue.closed {
+		select {
+		case s.queue.deliveries <- d:
+			return
+		default:
+		}
+	}
+
+	log.Println("Notification queue of", s.id, "is full or closed.")
+
+	if !d.quiet {
+		spoolReport(s, d.report)
+	}
+
+}
+
+// drainNotifiers stops accepting reports and waits up to timeout for every sink to deliver the queued ones.
+func drainNotifiers(timeout time.Duration) {
+
+	sinks := allSinks()
+
+	for _, s := range sinks {
+		s.queue.mutex.Lock()
+		if !s.queue.closed {
+			s.queue.closed = true
+			close(s.queue.deliveries)
+		}
+		s.queue.mutex.Unlock()
+	}
+
+	deadline := time.After(timeout)
+
+	for _, s := range sinks {
+		select {
+		case <-s.queue.done:
+		case <-deadline:
+			log.Println("Gave up on the queued notifications of", s.id+".")
+			return
+		}
+	}
+
+}
+
+// deliverToSink sends the report once everything spooled before it has been replayed, so that sinks keeping state,
+// such as alerts they open and close, end up showing the latest report. Reports that cannot be delivered, or would
+// overtake dead letters that cannot be replayed yet, are spooled.
+func deliverToSink(s sink, report cycleReport) {
+
+	if !replaySpool(s, report.Time) {
+		spoolReport(s, report)
+		return
+	}
+
+	if err := sendWithRetry(s, report); err != nil {
+		log.Println("Notification to", s.id, "failed:", err.Error())
+		spoolReport(s, report)
+	}
+
+}
+
+// resolveSink replays the dead letters of a sink for a report too quiet to notify about, and then lets a sink that
+// keeps alerts open close them. Alerts are left open while dead letters older than the report remain, as those
+// would reopen them.
+func resolveSink(s sink, report cycleReport) {
+
+	if !replaySpool(s, report.Time) {
+		return
+	}
+
+	if r, ok := s.notifier.(resolver); ok {
+		if err := r.Resolve(report); err != nil {
+			log.Println("Resolving alerts of", s.id, "failed:", err.Error())
+		}
+	}
+
+}
+
+// spoolDir returns the dead-letter directory of a sink, or an empty string when spooling is disabled.
+func spoolDir(s sink) string {
+
+	if config.Notifications.DeadLetter.Dir == "" {
+		return ""
+	}
+
+	return filepath.Join(config.Notifications.DeadLetter.Dir, s.id)
+
+}
+
+// spooledFiles returns the sink's dead letters, oldest first.
+func spooledFiles(dir string) []string {
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+	return files
+
+}
+
+// spoolReport writes an undeliverable report to the sink's dead-letter directory, named after the report's time so
+// that dead letters replay in the order of their cycles, dropping the oldest dead letters beyond max_files.
+func spoolReport(s sink, report cycleReport) {
+
+	dir := spoolDir(s)
+
+	if dir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Println("Cannot create dead-letter directory:", err.Error())
+		return
+	}
+
+	data, err := json.Marshal(report)
+
+	if err != nil {
+		log.Println("Cannot encode dead letter:", err.Error())
+		return
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("%020d.json", report.Time.UnixNano()))
+
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		log.Println("Cannot write dead letter:", err.Error())
+		return
+	}
+
+	log.Println("Notification for", s.id, "spooled to", name)
+
+	files := spooledFiles(dir)
+
+	for limit := config.Notifications.DeadLetter.MaxFiles; limit > 0 && len(files) > limit; files = files[1:] {
+		log.Println("Dead-letter spool for", s.id, "full, dropping", files[0])
+		os.Remove(files[0])
+	}
+
+}
+
+// replaySpool resends the sink's dead letters of reports older than the given time, oldest first, stopping at the
+// first failure. It returns true once none of them is left.
+func replaySpool(s sink, before time.Time) bool {
+
+	dir := spoolDir(s)
+
+	if dir == "" {
+		return true
+	}
+
+	for _, file := range spooledFiles(dir) {
+
+		data, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			log.Println("Cannot read dead letter:", err.Error())
+			continue
+		}
+
+		var report cycleReport
+
+		if err := json.Unmarshal(data, &report); err != nil {
+			log.Println("Dropping unreadable dead letter", file+":", err.Error())
+			os.Remove(file)
+			continue
+		}
+
+		if !report.Time.Before(before) {
+			break
+		}
+
+		if err := s.Notify(report); err != nil {
+			log.Println("Replay to", s.id, "failed, keeping dead letters:", err.Error())
+			return false
+		}
+
+		log.Println("Replayed dead letter", file, "to", s.id)
+		os.Remove(file)
+
+	}
+
+	return true
+
+}
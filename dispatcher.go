@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// eventQueueSize bounds the events waiting to be recorded. Recording is slow compared to finding orphans, so a burst
+// of findings queues up; beyond this, events are dropped rather than holding up the cycle.
+const eventQueueSize = 256
+
+// eventDispatcher records queued events against the apiserver from a single goroutine that lives as long as dcc.
+type eventDispatcher struct {
+	mutex  sync.Mutex
+	queue  chan dccEvent
+	closed bool
+	done   chan struct{}
+}
+
+var dispatcher = &eventDispatcher{queue: make(chan dccEvent, eventQueueSize), done: make(chan struct{})}
+
+// run records the queued events, subject to the event limits, until the queue is closed and drained.
+func (d *eventDispatcher) run() {
+
+	defer close(d.done)
+
+	for event := range d.queue {
+		if allowEvent(event) {
+			sendAnnotatedEvent(event)
+		}
+	}
+
+}
+
+// dispatch queues an event without blocking. Events that do not fit, or arrive after shutdown, are dropped.
+func (d *eventDispatcher) dispatch(event dccEvent) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		eventsSuppressed.WithLabelValues("shutdown").Inc()
+		return
+	}
+
+	select {
+	case d.queue <- event:
+	default:
+		eventsSuppressed.WithLabelValues("queue_full").Inc()
+	}
+
+}
+
+// shutdown stops accepting events and waits up to timeout for the queued ones to be recorded. It returns false if
+// events were still queued when the time ran out.
+func (d *eventDispatcher) shutdown(timeout time.Duration) bool {
+
+	d.mutex.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mutex.Unlock()
+
+	select {
+	case <-d.done:
+		return true
+	case <-time.After(timeout):
+		log.Println("Gave up on", len(d.queue), "queued events.")
+		return false
+	}
+
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Labels the kubelet's Docker integration puts on the containers it creates.
+const (
+	labelPodName       = "io.kubernetes.pod.name"
+	labelPodNamespace  = "io.kubernetes.pod.namespace"
+	labelPodUID        = "io.kubernetes.pod.uid"
+	labelContainerName = "io.kubernetes.container.name"
+)
+
+// annotationMirrorPod marks the apiserver's mirror of a static pod run by the kubelet.
+const annotationMirrorPod = "kubernetes.io/config.mirror"
+
+// annotationPrefix namespaces the machine-readable annotations dcc attaches to its events.
+const annotationPrefix = "dcc.kernelpanek.io/"
+
+// Rules and outcomes reported in event annotations.
+const (
+	ruleUnknownToKubernetes = "unknown-to-kubernetes"
+	ruleStuckTeardown       = "stuck-teardown"
+
+	outcomeReported = "reported"
+	outcomeStopped  = "stopped"
+	outcomeDeferred = "deferred"
+	outcomeFailed   = "failed"
+)
+
+// dccEvent is a Kubernetes event waiting to be recorded against the node.
+type dccEvent struct {
+	Reason      string
+	Message     string
+	Annotations map[string]string
+
+	// Object is the object the event is recorded against; the node when nil.
+	Object *v1.ObjectReference
+}
+
+// nodeEventReference refers to this node for events. Events about cluster-scoped objects land in the default
+// namespace unless a namespace is configured.
+func nodeEventReference() *v1.ObjectReference {
+	return &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       nodeReference.Name,
+		UID:        nodeReference.UID,
+		Namespace:  config.Events.Namespace,
+	}
+}
+
+// eventObjectFor returns the object an event about an orphan is recorded against. With involved_object set to
+// "pod", events go to the orphan's former pod in its namespace, where workload owners look for them; otherwise, or
+// when the pod is unknown, they go to the node.
+func eventObjectFor(owner podOwner) *v1.ObjectReference {
+
+	if config.Events.InvolvedObject != "pod" || owner.Name == "" || owner.Namespace == "" {
+		return nil
+	}
+
+	return &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       owner.Name,
+		Namespace:  owner.Namespace,
+		UID:        types.UID(owner.UID),
+	}
+
+}
+
+// containerAnnotations describes an orphan and what was done about it, so event consumers don't have to parse the
+// free-text message. Pod details are included when the owner could be inferred from the container's labels.
+func containerAnnotations(c dockertypes.Container, owner podOwner, rule, outcome string) map[string]string {
+
+	annotations := map[string]string{
+		annotationPrefix + "container-id": c.ID,
+		annotationPrefix + "image":        c.Image,
+		annotationPrefix + "image-id":     c.ImageID,
+		annotationPrefix + "rule":         rule,
+		annotationPrefix + "outcome":      outcome,
+	}
+
+	for key, value := range map[string]string{
+		"pod-name":       owner.Name,
+		"pod-namespace":  owner.Namespace,
+		"pod-uid":        owner.UID,
+		"container-name": owner.Container,
+		"workload":       owner.Workload,
+	} {
+		if value != "" {
+			annotations[annotationPrefix+key] = value
+		}
+	}
+
+	if owner.Name != "" {
+		annotations[annotationPrefix+"pod-state"] = owner.State
+	}
+
+	if s, ok := sightings[c.ID]; ok {
+		annotations[annotationPrefix+"seen-count"] = strconv.Itoa(s.Count)
+		annotations[annotationPrefix+"first-seen"] = s.FirstSeen.UTC().Format(time.RFC3339)
+	}
+
+	return annotations
+
+}
+
+var eventsSuppressed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "events_suppressed_total",
+	Help:      "Number of Kubernetes events not emitted, by cause.",
+}, []string{"cause"})
+
+func init() {
+	metrics.Registry.MustRegister(eventsSuppressed)
+}
+
+var (
+	eventMutex    sync.Mutex
+	eventLimiter  flowcontrol.RateLimiter
+	eventLastSent = map[string]time.Time{}
+)
+
+// setupEventLimits creates the rate limiter shared by all emitted events.
+func setupEventLimits() {
+
+	if config.Events.QPS > 0 {
+		eventLimiter = flowcontrol.NewTokenBucketRateLimiter(config.Events.QPS, config.Events.Burst)
+	}
+
+}
+
+// allowEvent decides whether an event is emitted. A finding for a container already reported with the same reason
+// and outcome is only repeated after repeat_interval; the repeat has the same message, so the recorder folds it into
+// the existing event's count and last timestamp. All events are subject to the overall rate limit.
+func allowEvent(event dccEvent) bool {
+
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+
+	now := time.Now()
+	id := event.Annotations[annotationPrefix+"container-id"]
+
+	if id != "" {
+
+		key := id + "/" + event.Reason + "/" + event.Annotations[annotationPrefix+"outcome"]
+		repeat := time.Duration(config.Events.RepeatInterval) * time.Second
+
+		if last, ok := eventLastSent[key]; ok && now.Sub(last) < repeat {
+			eventsSuppressed.WithLabelValues("duplicate").Inc()
+			return false
+		}
+
+		if eventLimiter != nil && !eventLimiter.TryAccept() {
+			eventsSuppressed.WithLabelValues("rate_limited").Inc()
+			return false
+		}
+
+		eventLastSent[key] = now
+		pruneEventHistory(now, repeat)
+		return true
+
+	}
+
+	if eventLimiter != nil && !eventLimiter.TryAccept() {
+		eventsSuppressed.WithLabelValues("rate_limited").Inc()
+		return false
+	}
+
+	return true
+
+}
+
+// pruneEventHistory forgets containers whose last event is older than the repeat interval.
+func pruneEventHistory(now time.Time, repeat time.Duration) {
+
+	for key, last := range eventLastSent {
+		if now.Sub(last) >= repeat {
+			delete(eventLastSent, key)
+		}
+	}
+
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// eventTTL is how long the apiserver keeps events by default; a series is not continued past it.
+const eventTTL = time.Hour
+
+// maxEventNote is the longest note events.k8s.io/v1 accepts.
+const maxEventNote = 1024
+
+// eventSeries is an events.k8s.io/v1 event that repeats of the same occurrence are folded into.
+type eventSeries struct {
+	name      string
+	namespace string
+	count     int
+	last      time.Time
+}
+
+var (
+	// eventsV1 is true when the apiserver serves events.k8s.io/v1. Older clusters get events through the core/v1
+	// recorder.
+	eventsV1 bool
+
+	seriesMutex sync.Mutex
+	series      = map[string]*eventSeries{}
+)
+
+// setupEventsAPI finds out whether the apiserver serves events.k8s.io/v1.
+func setupEventsAPI() {
+
+	err := kubeClient.CoreV1().RESTClient().Get().AbsPath("/apis/events.k8s.io/v1").Do().Error()
+	eventsV1 = err == nil
+
+	if !eventsV1 {
+		log.Println("events.k8s.io/v1 is not available, recording core/v1 events.")
+	}
+
+}
+
+// recordEvent records a warning event about the object. With events.k8s.io/v1, repeats of an event within its TTL
+// are folded into the first one's series rather than creating new events.
+func recordEvent(object *corev1.ObjectReference, annotations map[string]string, reason, message string) {
+
+	if !eventsV1 {
+		kubeRecorder.AnnotatedEventf(object, annotations, corev1.EventTypeWarning, reason, "%s", message)
+		return
+	}
+
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = corev1.NamespaceDefault
+	}
+
+	if len(message) > maxEventNote {
+		message = message[:maxEventNote]
+	}
+
+	key := namespace + "/" + object.Kind + "/" + object.Name + "/" + reason + "/" + message
+	now := time.Now()
+
+	seriesMutex.Lock()
+	defer seriesMutex.Unlock()
+
+	for k, s := range series {
+		if now.Sub(s.last) > eventTTL {
+			delete(series, k)
+		}
+	}
+
+	if s, ok := series[key]; ok {
+
+		err := patchEventSeries(s, now)
+
+		if err == nil {
+			return
+		}
+
+		delete(series, key)
+
+		if !errors.IsNotFound(err) {
+			log.Println("Cannot update event series:", err.Error())
+			return
+		}
+
+	}
+
+	s, err := createEvent(object, namespace, annotations, reason, message, now)
+
+	if err != nil {
+		log.Println("Cannot record event:", err.Error())
+		return
+	}
+
+	series[key] = s
+
+}
+
+// createEvent creates a new events.k8s.io/v1 event.
+func createEvent(object *corev1.ObjectReference, namespace string, annotations map[string]string, reason, message string, now time.Time) (*eventSeries, error) {
+
+	action := annotations[annotationPrefix+"outcome"]
+	if action == "" {
+		action = "Check"
+	}
+
+	name := fmt.Sprintf("%s.%x", object.Name, now.UnixNano())
+
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "events.k8s.io/v1",
+		"kind":       "Event",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   namespace,
+			"annotations": annotations,
+		},
+		"eventTime":           now.UTC().Format(microTimeFormat),
+		"reportingController": annotationPrefix + "container-checker",
+		"reportingInstance":   "container-checker-" + nodeFlag,
+		"action":              action,
+		"reason":              reason,
+		"regarding":           object,
+		"note":                message,
+		"type":                corev1.EventTypeWarning,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	err = kubeClient.CoreV1().RESTClient().Post().AbsPath(fmt.Sprintf("/apis/events.k8s.io/v1/namespaces/%s/events", namespace)).
+		SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+	observeCall("kubernetes", "CreateEvent", started, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventSeries{name: name, namespace: namespace, count: 1, last: now}, nil
+
+}
+
+// patchEventSeries counts another occurrence in the event's series.
+func patchEventSeries(s *eventSeries, now time.Time) error {
+
+	body, err := json.Marshal(map[string]interface{}{
+		"series": map[string]interface{}{
+			"count":            s.count + 1,
+			"lastObservedTime": now.UTC().Format(microTimeFormat),
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	err = kubeClient.CoreV1().RESTClient().Patch(types.MergePatchType).
+		AbsPath(fmt.Sprintf("/apis/events.k8s.io/v1/namespaces/%s/events/%s", s.namespace, s.name)).
+		Body(body).Do().Error()
+	observeCall("kubernetes", "PatchEvent", started, err)
+
+	if err != nil {
+		return err
+	}
+
+	s.count++
+	s.last = now
+
+	return nil
+
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// actionRecord is one exported row: a single orphan and what was done about it.
+type actionRecord struct {
+	Node        string `parquet:"name=node, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CycleTime   int64  `parquet:"name=cycle_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	ContainerID string `parquet:"name=container_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Image       string `parquet:"name=image, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ImageID     string `parquet:"name=image_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Created     int64  `parquet:"name=created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Pod         string `parquet:"name=pod, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Rule        string `parquet:"name=rule, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Outcome     string `parquet:"name=outcome, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SizeBytes   int64  `parquet:"name=size_bytes, type=INT64"`
+	Workload    string `parquet:"name=workload, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+var actionCSVHeader = []string{"node", "cycle_time", "container_id", "image", "image_id", "created", "pod", "rule", "outcome", "size_bytes", "workload"}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// actionRecords flattens a cycle report into one record per finding.
+func actionRecords(report cycleReport) []actionRecord {
+
+	var records []actionRecord
+
+	for _, f := range report.Findings {
+		records = append(records, actionRecord{
+			Node:        report.Node,
+			CycleTime:   millis(report.Time),
+			ContainerID: f.ContainerID,
+			Image:       f.Image,
+			ImageID:     f.ImageID,
+			Created:     millis(f.Created),
+			Pod:         f.Pod,
+			Rule:        f.Rule,
+			Outcome:     f.Outcome,
+			SizeBytes:   f.SizeBytes,
+			Workload:    f.Workload,
+		})
+	}
+
+	return records
+
+}
+
+// exportActions writes the cycle's actions to the export directory for loading into a data warehouse. CSV records
+// are appended to one file per UTC day; Parquet files cannot be appended to, so each cycle gets its own file.
+func exportActions(report cycleReport) {
+
+	dir := config.Reports.Export.Dir
+
+	if dir == "" || report.Found() == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println("Cannot create export directory:", err.Error())
+		return
+	}
+
+	var err error
+
+	if config.Reports.Export.Format == "parquet" {
+		err = exportParquet(filepath.Join(dir, fmt.Sprintf("actions-%s-%s.parquet", nodeFlag, report.Time.UTC().Format("20060102T150405Z"))), actionRecords(report))
+	} else {
+		err = exportCSV(filepath.Join(dir, fmt.Sprintf("actions-%s-%s.csv", nodeFlag, report.Time.UTC().Format("2006-01-02"))), actionRecords(report))
+	}
+
+	if err != nil {
+		log.Println("Cannot export actions:", err.Error())
+	}
+
+}
+
+func exportCSV(name string, records []actionRecord) error {
+
+	_, statErr := os.Stat(name)
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+
+	if os.IsNotExist(statErr) {
+		w.Write(actionCSVHeader)
+	}
+
+	for _, r := range records {
+		w.Write([]string{
+			r.Node,
+			time.Unix(0, r.CycleTime*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+			r.ContainerID,
+			r.Image,
+			r.ImageID,
+			time.Unix(0, r.Created*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+			r.Pod,
+			r.Rule,
+			r.Outcome,
+			strconv.FormatInt(r.SizeBytes, 10),
+			r.Workload,
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+
+}
+
+func exportParquet(name string, records []actionRecord) error {
+
+	file, err := local.NewLocalFileWriter(name)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	pw, err := writer.NewParquetWriter(file, new(actionRecord), 1)
+
+	if err != nil {
+		return err
+	}
+
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, r := range records {
+		if err := pw.Write(r); err != nil {
+			return err
+		}
+	}
+
+	return pw.WriteStop()
+
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var cycleFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "cycle_failures_total",
+	Help:      "Number of failures that broke part of a check cycle, by stage.",
+}, []string{"stage"})
+
+var cyclesAborted = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "cycles_aborted_total",
+	Help:      "Number of check cycles aborted because the pods or the containers on the node could not be listed.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(cycleFailuresTotal, cyclesAborted)
+}
+
+// abortedCycles counts the consecutive cycles aborted for lack of a pod or container list.
+var abortedCycles int
+
+// abortRetryDelay is the delay before the first retry of an aborted cycle.
+const abortRetryDelay = 5 * time.Second
+
+// cycleFailures collects what went wrong during a cycle. Docker and Kubernetes are queried concurrently, so adding
+// is safe from any goroutine.
+type cycleFailures struct {
+	mutex    sync.Mutex
+	messages []string
+	stages   map[string]bool
+}
+
+// add records a failure of the given stage of the cycle.
+func (f *cycleFailures) add(stage string, err error) {
+
+	cycleFailuresTotal.WithLabelValues(stage).Inc()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.messages = append(f.messages, stage+": "+err.Error())
+
+	if f.stages == nil {
+		f.stages = map[string]bool{}
+	}
+	f.stages[stage] = true
+
+}
+
+// fail records a failure of the given stage and returns the error, so a goroutine of the cycle can record a failure
+// and cancel its siblings at once.
+func (f *cycleFailures) fail(stage string, err error) error {
+	f.add(stage, err)
+	return err
+}
+
+// stageError is an error that names the stage of the cycle that failed.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e stageError) Error() string {
+	return e.err.Error()
+}
+
+// failed returns true if any of the given stages failed.
+func (f *cycleFailures) failed(stages ...string) bool {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, stage := range stages {
+		if f.stages[stage] {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// failedStages returns the stages that failed so far, in no particular order.
+func (f *cycleFailures) failedStages() []string {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var stages []string
+	for stage := range f.stages {
+		stages = append(stages, stage)
+	}
+
+	return stages
+
+}
+
+// list returns the failures recorded so far.
+func (f *cycleFailures) list() []string {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.messages...)
+
+}
+
+// reportCycleFailures records a warning event when the cycle was broken. A silently broken cleaner is worse than
+// none, so this is reported separately from orphan findings.
+func reportCycleFailures(failures []string) {
+
+	if len(failures) == 0 {
+		return
+	}
+
+	message := "Check cycle failed: " + strings.Join(failures, "; ")
+	log.Println(message)
+	sendEvent("CycleFailed", message)
+
+}
+
+// abortRetryAfter returns the delay after which an aborted cycle is retried, backing off exponentially up to the
+// check interval, and 0 while cycles complete.
+func abortRetryAfter() time.Duration {
+
+	if abortedCycles == 0 {
+		return 0
+	}
+
+	delay := abortRetryDelay << uint(abortedCycles-1)
+
+	if interval := time.Duration(config.Timing.CheckInterval) * time.Second; delay > interval || delay <= 0 {
+		delay = interval
+	}
+
+	return delay
+
+}
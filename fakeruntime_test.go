@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+)
+
+// notFoundError is the error Docker's client returns for a container that does not exist, which
+// docker.IsErrContainerNotFound recognizes by its NotFound method.
+type notFoundError struct {
+	id string
+}
+
+func (e notFoundError) Error() string {
+	return "Error: No such container: " + e.id
+}
+
+func (e notFoundError) NotFound() bool {
+	return true
+}
+
+// fakeRuntime is an in-memory dccruntime.Runtime, for running the comparison, the guards and the removals without a
+// Docker daemon. Stopped containers are no longer listed but can still be inspected, as with Docker; removed ones
+// are gone. Every call is recorded in calls.
+type fakeRuntime struct {
+	mutex      sync.Mutex
+	containers map[string]types.Container
+	running    map[string]bool
+
+	// listErr fails every List; stopErrs fail the Stop of single containers.
+	listErr  error
+	stopErrs map[string]error
+
+	calls []string
+}
+
+// newFakeRuntime returns a fake runtime running the given containers.
+func newFakeRuntime(containers ...types.Container) *fakeRuntime {
+
+	f := &fakeRuntime{
+		containers: map[string]types.Container{},
+		running:    map[string]bool{},
+		stopErrs:   map[string]error{},
+	}
+
+	for _, c := range containers {
+		f.containers[c.ID] = c
+		f.running[c.ID] = true
+	}
+
+	return f
+
+}
+
+func (f *fakeRuntime) List(ctx context.Context) ([]types.Container, error) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, "List")
+
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+
+	var containers []types.Container
+
+	for id, c := range f.containers {
+		if f.running[id] {
+			containers = append(containers, c)
+		}
+	}
+
+	return containers, nil
+
+}
+
+func (f *fakeRuntime) Inspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, "Inspect "+id)
+
+	c, ok := f.containers[id]
+
+	if !ok {
+		return types.ContainerJSON{}, notFoundError{id: id}
+	}
+
+	status := "exited"
+	if f.running[id] {
+		status = "running"
+	}
+
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		ID:      c.ID,
+		Created: time.Unix(c.Created, 0).UTC().Format(time.RFC3339Nano),
+		Image:   c.ImageID,
+		State:   &types.ContainerState{Status: status, Running: f.running[id]},
+	}}, nil
+
+}
+
+func (f *fakeRuntime) Stop(ctx context.Context, id string, timeout time.Duration) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, "Stop "+id)
+
+	if err := f.stopErrs[id]; err != nil {
+		return err
+	}
+
+	if _, ok := f.containers[id]; !ok {
+		return notFoundError{id: id}
+	}
+
+	f.running[id] = false
+	return nil
+
+}
+
+func (f *fakeRuntime) Remove(ctx context.Context, id string) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, "Remove "+id)
+
+	if f.running[id] {
+		return fmt.Errorf("container %s is running", id)
+	}
+
+	if _, ok := f.containers[id]; !ok {
+		return notFoundError{id: id}
+	}
+
+	delete(f.containers, id)
+	delete(f.running, id)
+	return nil
+
+}
+
+// fakeOrchestrator knows a fixed set of container IDs and records the events it is given.
+type fakeOrchestrator struct {
+	mutex  sync.Mutex
+	known  []string
+	err    error
+	events []dccEvent
+}
+
+func (f *fakeOrchestrator) KnownContainerIDs() ([]string, error) {
+	return f.known, f.err
+}
+
+func (f *fakeOrchestrator) NodeRef() *v1.ObjectReference {
+	return &v1.ObjectReference{Kind: "Node", Name: "node-1"}
+}
+
+func (f *fakeOrchestrator) Event(event dccEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.events = append(f.events, event)
+}
+
+// reasons returns the reasons of the recorded events in order.
+func (f *fakeOrchestrator) reasons() []string {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var reasons []string
+
+	for _, event := range f.events {
+		reasons = append(reasons, event.Reason)
+	}
+
+	return reasons
+
+}
+
+// useFakes puts the runtime and the orchestrator in place of the node's. The returned function restores them, with
+// the configuration and the stop cooldowns, for tests to defer.
+func useFakes(rt *fakeRuntime, orch *fakeOrchestrator) func() {
+
+	savedConfig, savedRuntime, savedOrchestrator := config, newContainerRuntime, nodeOrchestrator
+
+	newContainerRuntime = func() (dccruntime.Runtime, error) { return rt, nil }
+	nodeOrchestrator = orch
+
+	return func() {
+		config, newContainerRuntime, nodeOrchestrator = savedConfig, savedRuntime, savedOrchestrator
+		stopCooldowns = map[string]*stopCooldown{}
+	}
+
+}
+
+// testContainer returns a container of the given ID created an hour ago, old enough to be an orphan.
+func testContainer(id string) types.Container {
+	return types.Container{ID: id, Image: "busybox", ImageID: "sha256:" + id, Created: time.Now().Add(-time.Hour).Unix()}
+}
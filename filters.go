@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"golang.org/x/net/context"
+)
+
+// Verdicts a filter plugin can return for a candidate orphan.
+const (
+	verdictAllow  = "allow"
+	verdictVeto   = "veto"
+	verdictIgnore = "ignore"
+)
+
+// stageFilter is the cycle stage of failed filter plugins.
+const stageFilter = "filter"
+
+// defaultFilterTimeout bounds a filter plugin when no timeout is configured.
+const defaultFilterTimeout = 30 * time.Second
+
+// filterRequest is the JSON document a filter plugin receives on stdin: every candidate orphan of the cycle, as
+// findings without an outcome.
+type filterRequest struct {
+	Node       string    `json:"node"`
+	Candidates []finding `json:"candidates"`
+}
+
+// filterResponse is the JSON document a filter plugin writes to stdout. Candidates without a verdict are allowed.
+type filterResponse struct {
+	Verdicts []filterVerdict `json:"verdicts"`
+}
+
+// filterVerdict is a plugin's decision on one candidate. Veto leaves the container running, ignore drops it from the
+// cycle as not an orphan at all, and a rule reclassifies it, whatever the verdict.
+type filterVerdict struct {
+	ContainerID string `json:"containerID"`
+	Verdict     string `json:"verdict"`
+	Rule        string `json:"rule,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// filterResult is what the filter plugins decided in a cycle.
+type filterResult struct {
+	verdicts map[string]filterVerdict
+
+	// failed is why removals are deferred this cycle, when a plugin with the defer failure policy failed.
+	failed string
+}
+
+// filterOrphans hands the candidate orphans to every configured filter plugin in turn, so organizations can encode
+// rules of their own without them living in dcc's configuration. Candidates a plugin ignores are dropped; the other
+// verdicts are returned to be applied when the orphans are acted on. The first veto wins, and a later plugin's rule
+// replaces an earlier one's.
+func filterOrphans(ctx context.Context, orphans []types.Container, failures *cycleFailures) ([]types.Container, filterResult) {
+
+	result := filterResult{verdicts: map[string]filterVerdict{}}
+
+	if len(config.Filters) == 0 || len(orphans) == 0 {
+		return orphans, result
+	}
+
+	for _, filter := range config.Filters {
+
+		request := filterRequest{Node: nodeFlag}
+		for _, c := range orphans {
+			request.Candidates = append(request.Candidates, newFinding(c, inferPodOwner(c), ruleUnknownToKubernetes, "", ""))
+		}
+
+		response, err := runFilter(ctx, filter, request)
+
+		if err != nil {
+
+			log.Println("Filter", filter.Name, "failed:", err.Error())
+			failures.add(stageFilter, fmt.Errorf("%s: %s", filter.Name, err.Error()))
+
+			if filter.FailurePolicy != hookFailIgnore && result.failed == "" {
+				result.failed = fmt.Sprintf("filter %s failed: %s", filter.Name, err.Error())
+			}
+
+			continue
+
+		}
+
+		for _, v := range response.Verdicts {
+			result.merge(filter.Name, v)
+		}
+
+		orphans = withoutIgnored(orphans, result)
+
+	}
+
+	return orphans, result
+
+}
+
+// merge adds a plugin's verdict to those of the plugins before it.
+func (r filterResult) merge(plugin string, v filterVerdict) {
+
+	current, ok := r.verdicts[v.ContainerID]
+
+	if v.Rule != "" {
+		current.Rule = v.Rule
+	}
+
+	if (v.Verdict == verdictVeto || v.Verdict == verdictIgnore) && (!ok || current.Verdict == "" || current.Verdict == verdictAllow) {
+		current.Verdict = v.Verdict
+		current.Reason = plugin
+		if v.Reason != "" {
+			current.Reason += ": " + v.Reason
+		}
+	}
+
+	current.ContainerID = v.ContainerID
+	r.verdicts[v.ContainerID] = current
+
+}
+
+// withoutIgnored drops the orphans a plugin ignored.
+func withoutIgnored(orphans []types.Container, result filterResult) []types.Container {
+
+	var kept []types.Container
+
+	for _, c := range orphans {
+		if v := result.verdicts[c.ID]; v.Verdict == verdictIgnore {
+			log.Println("Ignoring container:", c.ID, "(", c.Image, ")", "Filter:", v.Reason)
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	return kept
+
+}
+
+// rule returns the rule a plugin reclassified the container under, or the given one.
+func (r filterResult) rule(id, rule string) string {
+
+	if v := r.verdicts[id]; v.Rule != "" {
+		return v.Rule
+	}
+
+	return rule
+
+}
+
+// veto returns why the filter plugins keep the container running, or an empty string.
+func (r filterResult) veto(id string) string {
+
+	if r.failed != "" {
+		return r.failed
+	}
+
+	if v := r.verdicts[id]; v.Verdict == verdictVeto {
+		return "vetoed by filter " + v.Reason
+	}
+
+	return ""
+
+}
+
+// runFilter runs a filter plugin with the request on stdin and decodes its response from stdout. A non-zero exit, a
+// response that is not valid JSON or running past the timeout is a failure.
+func runFilter(ctx context.Context, filter dccconfig.Filter, request filterRequest) (filterResponse, error) {
+
+	var response filterResponse
+
+	if len(filter.Command) == 0 {
+		return response, fmt.Errorf("no command")
+	}
+
+	body, err := json.Marshal(request)
+
+	if err != nil {
+		return response, err
+	}
+
+	timeout := time.Duration(filter.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultFilterTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, filter.Command[0], filter.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	started := time.Now()
+	err = cmd.Run()
+	observeCall("filter", filter.Name, started, err)
+
+	if err != nil {
+		if message := bytes.TrimSpace(stderr.Bytes()); len(message) > 0 {
+			return response, fmt.Errorf("%s: %s", err.Error(), message)
+		}
+		return response, err
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return response, fmt.Errorf("invalid response: %s", err.Error())
+	}
+
+	return response, nil
+
+}
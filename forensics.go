@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// defaultMaxLogBytes caps the logs saved with a snapshot when max_log_bytes is not configured.
+const defaultMaxLogBytes = 10 << 20
+
+// defaultMaxExportBytes caps an exported filesystem when max_export_bytes is not configured.
+const defaultMaxExportBytes = 512 << 20
+
+// exportTimeout is the time an export gets on top of the docker timeout, as large filesystems take a while to stream.
+const exportTimeout = 5 * time.Minute
+
+// lastExport is when a filesystem was last exported, for export_interval. Stops run in parallel, so it is guarded by
+// exportMutex.
+var (
+	exportMutex sync.Mutex
+	lastExport  time.Time
+)
+
+// snapshotContainer records what is about to be destroyed, so investigations after the fact can see exactly which
+// container was stopped and with what mounts, environment and processes. The container's full inspect document,
+// its process list with processes set and its logs with logs set are written to a new directory under the forensics
+// dir, whose path is returned. An empty path means snapshots are disabled, the runtime is not Docker or the
+// snapshot failed; the stop goes ahead either way.
+func snapshotContainer(ctx context.Context, cli *docker.Client, c types.Container) string {
+
+	root := config.Forensics.Dir
+
+	if root == "" || cli == nil {
+		return ""
+	}
+
+	dir := filepath.Join(root, fmt.Sprintf("%s-%.12s", time.Now().UTC().Format("20060102T150405Z"), c.ID))
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Println("Cannot create forensic snapshot directory:", err.Error())
+		return ""
+	}
+
+	inspectCtx, cancel := dockerContext(ctx, 0)
+	started := time.Now()
+	inspect, raw, err := cli.ContainerInspectWithRaw(inspectCtx, c.ID, false)
+	observeCall("docker", "ContainerInspect", started, err)
+	cancel()
+
+	if err != nil {
+		log.Println("Cannot inspect container", c.ID, "for its forensic snapshot:", err.Error())
+	} else {
+		writeSnapshotFile(dir, "inspect.json", raw, "application/json")
+	}
+
+	if config.Forensics.Processes {
+		writeProcessList(ctx, cli, c.ID, dir)
+	}
+
+	if config.Forensics.Logs {
+		writeContainerLogs(ctx, cli, c.ID, inspect.Config != nil && inspect.Config.Tty, dir)
+	}
+
+	if config.Forensics.Export {
+		exportFilesystem(ctx, cli, c.ID, dir)
+	}
+
+	pruneSnapshots(root)
+
+	return dir
+
+}
+
+// writeSnapshotFile writes one file of a snapshot, and uploads it to the report object storage with upload set. The
+// object key mirrors the snapshot directory under <prefix><node>/forensics/.
+func writeSnapshotFile(dir, name string, data []byte, contentType string) {
+
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		log.Println("Cannot write forensic snapshot:", err.Error())
+	}
+
+	if !config.Forensics.Upload || reportStore == nil {
+		return
+	}
+
+	key := fmt.Sprintf("%s%s/forensics/%s/%s", config.Reports.Upload.Prefix, nodeFlag, filepath.Base(dir), name)
+
+	started := time.Now()
+	err := reportStore.Put(key, data, contentType)
+	observeCall(config.Reports.Upload.Provider, "PutObject", started, err)
+
+	if err != nil {
+		log.Println("Forensic snapshot upload failed:", err.Error())
+	}
+
+}
+
+// writeProcessList adds the container's processes, as listed by docker top, to a snapshot.
+func writeProcessList(ctx context.Context, cli *docker.Client, id, dir string) {
+
+	topCtx, cancel := dockerContext(ctx, 0)
+	defer cancel()
+
+	started := time.Now()
+	processes, err := cli.ContainerTop(topCtx, id, nil)
+	observeCall("docker", "ContainerTop", started, err)
+
+	if err != nil {
+		log.Println("Cannot list processes of container", id, "for its forensic snapshot:", err.Error())
+		return
+	}
+
+	data, err := json.MarshalIndent(processes, "", "  ")
+
+	if err != nil {
+		log.Println("Cannot encode forensic snapshot:", err.Error())
+		return
+	}
+
+	writeSnapshotFile(dir, "processes.json", data, "application/json")
+
+}
+
+// writeContainerLogs adds the container's stdout and stderr to a snapshot as logs.txt.gz. Only the last
+// max_log_bytes are kept, as the end of the logs is where a failure shows.
+func writeContainerLogs(ctx context.Context, cli *docker.Client, id string, tty bool, dir string) {
+
+	maxBytes := config.Forensics.MaxLogBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxLogBytes
+	}
+
+	// The deadline also covers reading the stream below.
+	logsCtx, cancel := dockerContext(ctx, 0)
+	defer cancel()
+
+	started := time.Now()
+	reader, err := cli.ContainerLogs(logsCtx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	})
+	observeCall("docker", "ContainerLogs", started, err)
+
+	if err != nil {
+		log.Println("Cannot read logs of container", id, "for its forensic snapshot:", err.Error())
+		return
+	}
+
+	defer reader.Close()
+
+	tail := &tailBuffer{max: maxBytes}
+
+	// Containers without a TTY multiplex stdout and stderr; TTY containers return the raw stream.
+	if tty {
+		_, err = io.Copy(tail, reader)
+	} else {
+		_, err = stdcopy.StdCopy(tail, tail, reader)
+	}
+
+	// Whatever was read before a failure is still worth keeping.
+	if err != nil {
+		log.Println("Cannot read logs of container", id, "for its forensic snapshot:", err.Error())
+	}
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(tail.Bytes())
+
+	if err := w.Close(); err != nil {
+		log.Println("Cannot compress forensic snapshot:", err.Error())
+		return
+	}
+
+	writeSnapshotFile(dir, "logs.txt.gz", compressed.Bytes(), "application/gzip")
+
+}
+
+// exportFilesystem adds the container's filesystem, as exported by docker export, to a snapshot as
+// filesystem.tar.gz, for security to analyze suspicious orphans later. Exports are large and slow, so at most one
+// runs per export_interval and one exceeding max_export_bytes before compression is discarded.
+func exportFilesystem(ctx context.Context, cli *docker.Client, id, dir string) {
+
+	interval := time.Duration(config.Forensics.ExportInterval) * time.Second
+
+	exportMutex.Lock()
+
+	if !lastExport.IsZero() && time.Since(lastExport) < interval {
+		log.Println("Skipping filesystem export of container", id+": last export was", time.Since(lastExport).Round(time.Second), "ago")
+		exportMutex.Unlock()
+		return
+	}
+
+	lastExport = time.Now()
+	exportMutex.Unlock()
+
+	maxBytes := config.Forensics.MaxExportBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxExportBytes
+	}
+
+	// The deadline also covers reading the stream below.
+	exportCtx, cancel := dockerContext(ctx, exportTimeout)
+	defer cancel()
+
+	started := time.Now()
+	reader, err := cli.ContainerExport(exportCtx, id)
+	observeCall("docker", "ContainerExport", started, err)
+
+	if err != nil {
+		log.Println("Cannot export filesystem of container", id+":", err.Error())
+		return
+	}
+
+	defer reader.Close()
+
+	path := filepath.Join(dir, "filesystem.tar.gz")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		log.Println("Cannot write forensic snapshot:", err.Error())
+		return
+	}
+
+	w := gzip.NewWriter(file)
+	n, err := io.Copy(w, io.LimitReader(reader, maxBytes+1))
+
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	switch {
+	case err != nil:
+		log.Println("Cannot export filesystem of container", id+":", err.Error())
+	case n > maxBytes:
+		log.Println("Discarding filesystem export of container", id+": larger than", maxBytes, "bytes")
+	default:
+		uploadExport(path, dir)
+		return
+	}
+
+	os.Remove(path)
+
+}
+
+// uploadExport uploads an exported filesystem with upload set. It is kept apart from writeSnapshotFile as the
+// export is already on disk.
+func uploadExport(path, dir string) {
+
+	if !config.Forensics.Upload || reportStore == nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Println("Forensic snapshot upload failed:", err.Error())
+		return
+	}
+
+	key := fmt.Sprintf("%s%s/forensics/%s/%s", config.Reports.Upload.Prefix, nodeFlag, filepath.Base(dir), filepath.Base(path))
+
+	started := time.Now()
+	err = reportStore.Put(key, data, "application/gzip")
+	observeCall(config.Reports.Upload.Provider, "PutObject", started, err)
+
+	if err != nil {
+		log.Println("Forensic snapshot upload failed:", err.Error())
+	}
+
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+
+	t.data = append(t.data, p...)
+
+	// Trim only once twice the cap is buffered, so each byte is copied a bounded number of times.
+	if len(t.data) > 2*t.max {
+		t.data = append([]byte(nil), t.data[len(t.data)-t.max:]...)
+	}
+
+	return len(p), nil
+
+}
+
+func (t *tailBuffer) Bytes() []byte {
+
+	if len(t.data) > t.max {
+		return t.data[len(t.data)-t.max:]
+	}
+
+	return t.data
+
+}
+
+// pruneSnapshots removes the oldest snapshots beyond max_snapshots. Uploaded copies are left to the bucket's own
+// lifecycle rules.
+func pruneSnapshots(root string) {
+
+	limit := config.Forensics.MaxSnapshots
+
+	if limit <= 0 {
+		return
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(root, "*-*"))
+	sort.Strings(dirs)
+
+	for ; len(dirs) > limit; dirs = dirs[1:] {
+		if err := os.RemoveAll(dirs[0]); err != nil {
+			log.Println("Cannot remove forensic snapshot:", err.Error())
+		}
+	}
+
+}
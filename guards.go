@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+)
+
+// removalGuard vetoes stopping an orphan by returning the reason, or an empty string to let the removal proceed.
+type removalGuard func(c types.Container, owner podOwner) string
+
+// removalGuards returns the guards that apply to this cycle's removals. Guards that need fresh data fetch it once
+// here rather than once per container.
+func removalGuards(ctx context.Context, rt dccruntime.Runtime, failures *cycleFailures) []removalGuard {
+
+	var guards []removalGuard
+
+	if reason := acquireNodeLock(); reason != "" {
+		return []removalGuard{nodeLockGuard(reason)}
+	}
+
+	if state, _ := breaker.current(); state != breakerClosed {
+		return []removalGuard{breakerGuard}
+	}
+
+	if failures.failed(stageMassOrphans) {
+		return []removalGuard{massOrphanGuard}
+	}
+
+	guards = append(guards, cooldownGuard, nodeGuard(failures), podUIDGuard, sandboxGuard(ctx, rt, failures))
+
+	if config.Sanity.MinSightings > 1 {
+		guards = append(guards, sightingGuard)
+	}
+
+	if config.Kubelet.CrossCheck {
+		guards = append(guards, kubeletGuard(failures))
+	}
+
+	if config.DanglingContainers.Namespace != "" {
+		guards = append(guards, danglingContainerGuard())
+	}
+
+	if approvalRequired() {
+		guards = append(guards, approvalGuard(failures))
+	}
+
+	// The controller is asked last, so removals vetoed locally do not use up the cluster-wide budget.
+	if roleFlag == roleAgent {
+		guards = append(guards, controllerGuard())
+	}
+
+	return guards
+
+}
+
+// vetoRemoval returns the reason of the first guard that vetoes stopping the container.
+func vetoRemoval(guards []removalGuard, c types.Container, owner podOwner) string {
+
+	for _, guard := range guards {
+		if reason := guard(c, owner); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+
+}
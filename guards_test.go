@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"golang.org/x/net/context"
+)
+
+func TestVetoRemoval(t *testing.T) {
+
+	allow := func(c types.Container, owner podOwner) string { return "" }
+	deny := func(reason string) removalGuard {
+		return func(c types.Container, owner podOwner) string { return reason }
+	}
+
+	tests := []struct {
+		name   string
+		guards []removalGuard
+		want   string
+	}{
+		{"no guards", nil, ""},
+		{"all allow", []removalGuard{allow, allow}, ""},
+		{"first veto wins", []removalGuard{allow, deny("first"), deny("second")}, "first"},
+	}
+
+	for _, test := range tests {
+		if got := vetoRemoval(test.guards, testContainer(testID("a1")), podOwner{}); got != test.want {
+			t.Errorf("%s: vetoRemoval = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+}
+
+func TestCooldownGuard(t *testing.T) {
+
+	defer useFakes(newFakeRuntime(), &fakeOrchestrator{})()
+
+	config.Timing.StopCooldown = dccconfig.Cooldown{InitialDelay: 60, MaxDelay: 600, EscalateAfter: 2}
+
+	c := testContainer(testID("a1"))
+
+	if reason := cooldownGuard(c, podOwner{}); reason != "" {
+		t.Fatalf("cooldownGuard vetoed a container that never failed: %q", reason)
+	}
+
+	if recordStopFailure(c.ID) {
+		t.Error("first failure escalated, want escalation after 2")
+	}
+
+	if reason := cooldownGuard(c, podOwner{}); reason == "" {
+		t.Error("cooldownGuard let a container on cooldown through")
+	}
+
+	if !recordStopFailure(c.ID) {
+		t.Error("second failure did not escalate")
+	}
+
+	if recordStopFailure(c.ID) {
+		t.Error("third failure escalated again")
+	}
+
+	if cd := stopCooldowns[c.ID]; cd == nil || time.Until(cd.until) > 600*time.Second {
+		t.Errorf("cooldown %v exceeds max_delay", cd)
+	}
+
+	clearStopCooldown(c.ID)
+
+	if reason := cooldownGuard(c, podOwner{}); reason != "" {
+		t.Errorf("cooldownGuard vetoed a cleared container: %q", reason)
+	}
+
+}
+
+func TestPodUIDGuard(t *testing.T) {
+
+	tests := []struct {
+		owner podOwner
+		veto  bool
+	}{
+		{podOwner{}, false},
+		{podOwner{Name: "web-1", UID: "uid-1", State: podStateDeleted}, false},
+		{podOwner{Name: "web-1", UID: "uid-1", State: podStateReplaced}, false},
+		{podOwner{Name: "web-1", UID: "uid-1", State: podStateExists}, true},
+		{podOwner{Name: "web-1", UID: "uid-1"}, true},
+	}
+
+	for _, test := range tests {
+		if reason := podUIDGuard(testContainer(testID("a1")), test.owner); (reason != "") != test.veto {
+			t.Errorf("podUIDGuard(%+v) = %q, want veto %v", test.owner, reason, test.veto)
+		}
+	}
+
+}
+
+func TestSandboxGuard(t *testing.T) {
+
+	shared, left, app := testID("a1"), testID("b2"), testID("c3")
+
+	sandbox := func(id string) types.Container {
+		c := testContainer(id)
+		c.Labels = map[string]string{labelContainerType: containerTypeSandbox}
+		return c
+	}
+
+	member := testContainer(app)
+	member.Labels = map[string]string{labelSandboxID: shared}
+
+	rt := newFakeRuntime(sandbox(shared), sandbox(left), member)
+	defer useFakes(rt, &fakeOrchestrator{})()
+
+	var failures cycleFailures
+	guard := sandboxGuard(context.Background(), rt, &failures)
+
+	if reason := guard(sandbox(shared), podOwner{}); reason == "" {
+		t.Error("sandbox with a running member was not vetoed")
+	}
+
+	if reason := guard(sandbox(left), podOwner{}); reason != "" {
+		t.Errorf("sandbox without members was vetoed: %q", reason)
+	}
+
+	if reason := guard(member, podOwner{}); reason != "" {
+		t.Errorf("container that is no sandbox was vetoed: %q", reason)
+	}
+
+	rt.listErr = errors.New("daemon unavailable")
+	guard = sandboxGuard(context.Background(), rt, &failures)
+
+	if reason := guard(sandbox(left), podOwner{}); reason == "" {
+		t.Error("sandbox was not vetoed when its siblings could not be listed")
+	}
+
+	if !failures.failed("sandbox-siblings") {
+		t.Error("failed listing not recorded as a cycle failure")
+	}
+
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// renewHeartbeatLease renews this node's heartbeat Lease with the counts of the cycle, so a dashboard or controller
+// can spot dead or wedged agents across the fleet from the Leases alone. The lease duration is three check
+// intervals; a lease not renewed within it belongs to an agent that stopped checking.
+func renewHeartbeatLease(report cycleReport) {
+
+	namespace := config.Heartbeat.Namespace
+
+	if namespace == "" {
+		return
+	}
+
+	name := "dcc-heartbeat-" + nodeFlag
+
+	current, err := getLease(namespace, name)
+
+	if err != nil {
+		log.Println("Cannot read heartbeat Lease:", err.Error())
+		return
+	}
+
+	now := time.Now().UTC().Format(microTimeFormat)
+
+	if current == nil {
+		current = &lease{
+			Metadata: leaseMetadata{Name: name, Namespace: namespace, Labels: map[string]string{"app": "dcc", "dcc.kernelpanek.io/node": nodeFlag}},
+			Spec:     leaseSpec{AcquireTime: now},
+		}
+	}
+
+	current.Spec.HolderIdentity = leaderIdentity()
+	current.Spec.LeaseDurationSeconds = int32(3 * config.Timing.CheckInterval)
+	current.Spec.RenewTime = now
+
+	if current.Metadata.Annotations == nil {
+		current.Metadata.Annotations = map[string]string{}
+	}
+
+	current.Metadata.Annotations[annotationLastScan] = report.Time.UTC().Format(time.RFC3339)
+	current.Metadata.Annotations[annotationOrphansFound] = strconv.Itoa(report.Found())
+	current.Metadata.Annotations[annotationOrphansRemoved] = strconv.Itoa(report.Removed())
+	current.Metadata.Annotations[annotationPrefix+"cycle-failures"] = strconv.Itoa(len(report.Failures))
+	current.Metadata.Annotations[annotationPrefix+"mode"] = report.Mode
+	current.Metadata.Annotations[annotationPrefix+"version"] = version
+
+	if err := saveLease(current); err != nil {
+		log.Println("Cannot renew heartbeat Lease:", err.Error())
+	}
+
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Hook phases, and what a failed pre hook does to the removal.
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+
+	hookFailIgnore = "ignore"
+	hookFailDefer  = "defer"
+)
+
+// defaultHookTimeout bounds a hook when no timeout is configured.
+const defaultHookTimeout = 10 * time.Second
+
+var hookFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "dcc",
+	Name:      "hook_failures_total",
+	Help:      "Number of failed removal hooks, by hook and phase.",
+}, []string{"hook", "phase"})
+
+func init() {
+	metrics.Registry.MustRegister(hookFailures)
+}
+
+// hookPayload is the JSON document a hook receives, on stdin for commands and as the request body for URLs.
+type hookPayload struct {
+	Phase   string  `json:"phase"`
+	Node    string  `json:"node"`
+	Finding finding `json:"finding"`
+}
+
+// runPreHooks runs the pre hooks of a removal in order. It returns why the removal is deferred when a hook with the
+// defer failure policy fails, which skips the hooks after it.
+func runPreHooks(ctx context.Context, f finding) string {
+
+	for _, hook := range config.Hooks.Pre {
+
+		err := runHook(ctx, hook, hookPayload{Phase: hookPre, Node: nodeFlag, Finding: f})
+
+		if err != nil && hook.FailurePolicy == hookFailDefer {
+			return fmt.Sprintf("pre hook %s failed: %s", hook.Name, err.Error())
+		}
+
+	}
+
+	return ""
+
+}
+
+// runPostHooks runs the post hooks of a removal, whatever its outcome, which the finding carries. Their failures are
+// only logged and counted: the container is already stopped, or failed to stop, by then.
+func runPostHooks(ctx context.Context, f finding) {
+
+	for _, hook := range config.Hooks.Post {
+		runHook(ctx, hook, hookPayload{Phase: hookPost, Node: nodeFlag, Finding: f})
+	}
+
+}
+
+// runHook runs a command, or calls a URL, with the payload, within the hook's timeout.
+func runHook(ctx context.Context, hook dccconfig.Hook, payload hookPayload) error {
+
+	body, err := json.Marshal(payload)
+
+	if err == nil {
+
+		timeout := time.Duration(hook.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = defaultHookTimeout
+		}
+
+		hookCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		started := time.Now()
+
+		if len(hook.Command) > 0 {
+			err = execHook(hookCtx, hook, payload, body)
+		} else {
+			err = callHook(hookCtx, hook, body)
+		}
+
+		observeCall("hook", hook.Name, started, err)
+
+	}
+
+	if err != nil {
+		log.Println("Hook", hook.Name, "failed for container", payload.Finding.ContainerID+":", err.Error())
+		hookFailures.WithLabelValues(hook.Name, payload.Phase).Inc()
+	}
+
+	return err
+
+}
+
+// execHook runs the hook's command with the payload on stdin. The phase and the container ID are also set in the
+// environment, for scripts that need nothing else. A non-zero exit is a failure.
+func execHook(ctx context.Context, hook dccconfig.Hook, payload hookPayload, body []byte) error {
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "DCC_HOOK_PHASE="+payload.Phase, "DCC_CONTAINER_ID="+payload.Finding.ContainerID)
+
+	output, err := cmd.CombinedOutput()
+
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%s: %s", err.Error(), bytes.TrimSpace(output))
+	}
+
+	return err
+
+}
+
+// callHook POSTs the payload to the hook's URL. A status of 300 or above is a failure.
+func callHook(ctx context.Context, hook dccconfig.Hook, body []byte) error {
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("hook returned %s", resp.Status)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateNodeIdentity makes sure the node dcc was told to check is the host it runs on, and returns an error
+// otherwise. A wrong node name, e.g. a copy-pasted NODE variable, would compare this host's containers with another
+// node's pods and stop innocent containers. The node is checked against the node the pod is scheduled to, when
+// POD_NAME and POD_NAMESPACE come from the downward API, and against the host name of the Docker daemon.
+func validateNodeIdentity(node *v1.Node) error {
+
+	if name, namespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE"); name != "" && namespace != "" {
+
+		started := time.Now()
+		pod, err := kubeClient.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		observeCall("kubernetes", "GetPod", started, err)
+
+		switch {
+		case err != nil:
+			log.Println("Cannot read own pod to validate the node:", err.Error())
+		case pod.Spec.NodeName != node.Name:
+			return fmt.Errorf("configured for node %s but scheduled to %s", node.Name, pod.Spec.NodeName)
+		}
+
+	}
+
+	cli, err := sharedDockerClient()
+
+	if err != nil {
+		log.Println("Cannot connect to Docker daemon to validate the node:", err.Error())
+		return nil
+	}
+
+	ctx, cancel := dockerContext(context.Background(), 0)
+	defer cancel()
+
+	started := time.Now()
+	info, err := cli.Info(ctx)
+	observeCall("docker", "Info", started, err)
+
+	if err != nil {
+		log.Println("Cannot read Docker daemon info to validate the node:", err.Error())
+		return nil
+	}
+
+	if !sameHost(info.Name, node) {
+		return fmt.Errorf("configured for node %s but the Docker daemon runs on %s", node.Name, info.Name)
+	}
+
+	return nil
+
+}
+
+// sameHost returns true if the host name is the node's name or host name address, or the short form of either.
+func sameHost(hostname string, node *v1.Node) bool {
+
+	names := []string{node.Name}
+
+	for _, a := range node.Status.Addresses {
+		if a.Type == v1.NodeHostName {
+			names = append(names, a.Address)
+		}
+	}
+
+	short := func(name string) string {
+		return strings.ToLower(strings.SplitN(name, ".", 2)[0])
+	}
+
+	for _, name := range names {
+		if short(name) == short(hostname) {
+			return true
+		}
+	}
+
+	return false
+
+}
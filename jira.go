@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// chronicOffender is an image that left orphans on several nodes within the window.
+type chronicOffender struct {
+	Image   string
+	Nodes   []string
+	Orphans int
+}
+
+// jiraUpdated remembers when an issue was last updated, so evidence is added at most once per window.
+var jiraUpdated = map[string]time.Time{}
+
+// runJiraChecks periodically looks for chronic offenders across the fleet and files or updates Jira issues for
+// them. The fleet view comes from the summary ConfigMaps every node maintains, so summaries must be enabled.
+func runJiraChecks() {
+
+	cfg := config.Notifications.Jira
+
+	if cfg.URL == "" {
+		return
+	}
+
+	if config.Summary.ConfigMapNamespace == "" {
+		log.Println("Jira issues disabled: they need the summary ConfigMaps (summary.configmap_namespace).")
+		return
+	}
+
+	token, err := readSecret(cfg.APITokenFile)
+
+	if err != nil {
+		log.Println("Jira issues disabled:", err.Error())
+		return
+	}
+
+	interval := time.Duration(cfg.CheckInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	// Spread the fleet's checks over the interval.
+	time.Sleep(time.Duration(rand.Int63n(int64(interval))))
+
+	for {
+
+		for _, offender := range findChronicOffenders() {
+			if err := fileJiraIssue(cfg, token, offender); err != nil {
+				log.Println("Cannot file Jira issue for", offender.Image+":", err.Error())
+			}
+		}
+
+		time.Sleep(interval)
+
+	}
+
+}
+
+// findChronicOffenders aggregates the summary ConfigMaps of all nodes and returns the images with orphans on at
+// least min_nodes nodes within the window. Only the alphabetically first of those nodes reports an offender, so
+// the fleet files one issue rather than one per node.
+func findChronicOffenders() []chronicOffender {
+
+	cfg := config.Notifications.Jira
+	window := time.Duration(cfg.Window) * time.Second
+	since := time.Now().Add(-window).Truncate(time.Hour)
+
+	configMaps, err := kubeClient.CoreV1().ConfigMaps(config.Summary.ConfigMapNamespace).List(metav1.ListOptions{LabelSelector: "app=dcc"})
+
+	if err != nil {
+		log.Println("Cannot list summary ConfigMaps:", err.Error())
+		return nil
+	}
+
+	nodes := map[string]map[string]bool{}
+	orphans := map[string]int{}
+
+	for _, cm := range configMaps.Items {
+
+		node := cm.Labels["dcc.kernelpanek.io/node"]
+
+		var buckets []summaryBucket
+
+		if node == "" || json.Unmarshal([]byte(cm.Data["buckets.json"]), &buckets) != nil {
+			continue
+		}
+
+		for _, b := range buckets {
+
+			if b.Hour.Before(since) {
+				continue
+			}
+
+			for image, count := range b.Images {
+				if nodes[image] == nil {
+					nodes[image] = map[string]bool{}
+				}
+				nodes[image][node] = true
+				orphans[image] += count
+			}
+
+		}
+
+	}
+
+	var offenders []chronicOffender
+
+	for image, nodeSet := range nodes {
+
+		if len(nodeSet) < cfg.MinNodes {
+			continue
+		}
+
+		offender := chronicOffender{Image: image, Orphans: orphans[image]}
+		for node := range nodeSet {
+			offender.Nodes = append(offender.Nodes, node)
+		}
+		sort.Strings(offender.Nodes)
+
+		if offender.Nodes[0] == nodeFlag {
+			offenders = append(offenders, offender)
+		}
+
+	}
+
+	return offenders
+
+}
+
+// jiraLabel identifies the issue of an image; image names are not valid Jira labels, so a digest of it is used.
+func jiraLabel(image string) string {
+	sum := sha256.Sum256([]byte(image))
+	return "dcc-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// fileJiraIssue opens an issue for the offender, or adds the latest evidence to the open one.
+func fileJiraIssue(cfg dccconfig.Jira, token string, offender chronicOffender) error {
+
+	label := jiraLabel(offender.Image)
+	window := time.Duration(cfg.Window) * time.Second
+
+	if last, ok := jiraUpdated[label]; ok && time.Since(last) < window {
+		return nil
+	}
+
+	evidence := fmt.Sprintf("Image %s left %d orphaned container(s) on %d nodes within %s:\n%s",
+		offender.Image, offender.Orphans, len(offender.Nodes), window, strings.Join(offender.Nodes, "\n"))
+
+	var search struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`, cfg.Project, label)
+
+	if err := jiraRequest(cfg, token, http.MethodGet, "/rest/api/2/search?jql="+url.QueryEscape(jql), nil, &search); err != nil {
+		return err
+	}
+
+	if len(search.Issues) > 0 {
+
+		key := search.Issues[0].Key
+
+		if err := jiraRequest(cfg, token, http.MethodPost, "/rest/api/2/issue/"+key+"/comment", map[string]string{"body": evidence}, nil); err != nil {
+			return err
+		}
+
+		log.Println("Updated Jira issue", key, "for", offender.Image)
+
+	} else {
+
+		issueType := cfg.IssueType
+		if issueType == "" {
+			issueType = "Task"
+		}
+
+		var created struct {
+			Key string `json:"key"`
+		}
+
+		err := jiraRequest(cfg, token, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{
+			"fields": map[string]interface{}{
+				"project":     map[string]string{"key": cfg.Project},
+				"issuetype":   map[string]string{"name": issueType},
+				"summary":     "Chronic dangling containers from " + offender.Image,
+				"description": evidence,
+				"labels":      []string{"dcc", label},
+			},
+		}, &created)
+
+		if err != nil {
+			return err
+		}
+
+		log.Println("Opened Jira issue", created.Key, "for", offender.Image)
+
+	}
+
+	jiraUpdated[label] = time.Now()
+	return nil
+
+}
+
+// jiraRequest calls the Jira REST API with basic authentication and decodes the response into result.
+func jiraRequest(cfg dccconfig.Jira, token, method, path string, payload, result interface{}) error {
+
+	var body *bytes.Reader
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(cfg.URL, "/")+path, body)
+
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(cfg.Username, token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("jira returned %s", resp.Status)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+
+}
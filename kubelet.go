@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"k8s.io/api/core/v1"
+)
+
+const defaultKubeletPort = 10250
+
+// kubeletURL returns the base URL of this node's kubelet, taken from the configuration or the Node object.
+func kubeletURL() string {
+
+	address := config.Kubelet.Address
+
+	if address == "" {
+		for _, a := range nodeReference.Status.Addresses {
+			if a.Type == v1.NodeInternalIP {
+				address = a.Address
+				break
+			}
+		}
+	}
+
+	port := config.Kubelet.Port
+
+	if port == 0 {
+		port = int(nodeReference.Status.DaemonEndpoints.KubeletEndpoint.Port)
+	}
+
+	if port == 0 {
+		port = defaultKubeletPort
+	}
+
+	return "https://" + net.JoinHostPort(address, strconv.Itoa(port))
+
+}
+
+var (
+	kubeletMutex  sync.Mutex
+	kubeletShared *http.Client
+)
+
+// kubeletClient returns the HTTP client for the kubelet's authenticated port, built on first use and shared by every
+// later call so that its connections are reused. A client that cannot be built is attempted again on the next call.
+func kubeletClient() (*http.Client, error) {
+
+	kubeletMutex.Lock()
+	defer kubeletMutex.Unlock()
+
+	if kubeletShared != nil {
+		return kubeletShared, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Kubelet.InsecureSkipVerify}
+
+	if config.Kubelet.CAFile != "" {
+
+		pem, err := ioutil.ReadFile(config.Kubelet.CAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(pem)
+		tlsConfig.RootCAs = pool
+
+	}
+
+	kubeletShared = &http.Client{Timeout: 15 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return kubeletShared, nil
+
+}
+
+// kubeletPods fetches the pods the kubelet itself is running from its /pods endpoint.
+func kubeletPods() (*v1.PodList, error) {
+
+	client, err := kubeletClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, kubeletURL()+"/pods", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := readSecret(config.Kubelet.TokenFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	observeCall("kubelet", "ListPods", started, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet returned %s", resp.Status)
+	}
+
+	var pods v1.PodList
+	return &pods, json.NewDecoder(resp.Body).Decode(&pods)
+
+}
+
+// kubeletGuard defers removal of containers the kubelet still knows about even though the apiserver does not, which
+// points at apiserver staleness or a kubelet sync race. If the kubelet cannot be asked, every removal is deferred.
+func kubeletGuard(failures *cycleFailures) removalGuard {
+
+	pods, err := kubeletPods()
+
+	if err != nil {
+		failures.add("kubelet-pods", err)
+		return func(types.Container, podOwner) string {
+			return "kubelet pod list unavailable"
+		}
+	}
+
+	var ids []string
+
+	for i := range pods.Items {
+		ids = append(ids, kube.PodContainerIDs(&pods.Items[i])...)
+	}
+
+	known := newContainerIDSet(ids)
+
+	return func(c types.Container, owner podOwner) string {
+		if known.Contains(c.ID) {
+			return "kubelet still tracks the container"
+		}
+		return ""
+	}
+
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+)
+
+// nodeLeaseNamespace holds the leases kubelets renew as their heartbeat.
+const nodeLeaseNamespace = "kube-node-lease"
+
+// kubeletVeto returns why the kubelet cannot be trusted to have reported the node's pods, or an empty string if it
+// can: when the node is not Ready, or when the kubelet's last heartbeat is older than max_status_age. The heartbeat
+// is the later of the Ready condition's and the node lease's, as kubelets with a lease update their status rarely.
+func kubeletVeto(node *v1.Node, now time.Time) string {
+
+	var ready *v1.NodeCondition
+
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == v1.NodeReady {
+			ready = &node.Status.Conditions[i]
+		}
+	}
+
+	if ready == nil {
+		return "node has no Ready condition"
+	}
+
+	if ready.Status != v1.ConditionTrue {
+		return "kubelet is not ready: " + ready.Reason
+	}
+
+	maxAge := time.Duration(config.Kubelet.MaxStatusAge) * time.Second
+
+	if maxAge <= 0 {
+		return ""
+	}
+
+	heartbeat := ready.LastHeartbeatTime.Time
+
+	// The lease is optional; without it, or without permission to read it, the condition alone counts.
+	if l, err := getLease(nodeLeaseNamespace, node.Name); err == nil && l != nil {
+		if renewed, err := time.Parse(microTimeFormat, l.Spec.RenewTime); err == nil && renewed.After(heartbeat) {
+			heartbeat = renewed
+		}
+	}
+
+	if age := now.Sub(heartbeat); age > maxAge {
+		return fmt.Sprintf("kubelet last reported %s ago", age.Round(time.Second))
+	}
+
+	return ""
+
+}
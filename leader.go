@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// leading is 1 while this instance holds the node's leader election.
+var leading int32
+
+// isLeader returns true if this instance may act on the node. Without leader election every instance may.
+func isLeader() bool {
+	return !config.LeaderElection.Enabled || atomic.LoadInt32(&leading) == 1
+}
+
+// leaderIdentity identifies this instance in the heartbeat lease.
+func leaderIdentity() string {
+
+	name := os.Getenv("POD_NAME")
+
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	return fmt.Sprintf("%s_%d", name, os.Getpid())
+
+}
+
+// leaderTimings returns the lease duration, the renew deadline and the retry period. A renew deadline that is not
+// well below the lease duration falls back to two thirds of it, and a retry period that does not fit a few times
+// into the renew deadline to a quarter of it.
+func leaderTimings() (duration, renewDeadline, retryPeriod time.Duration) {
+
+	duration = time.Duration(config.LeaderElection.LeaseDuration) * time.Second
+	renewDeadline = time.Duration(config.LeaderElection.RenewDeadline) * time.Second
+	retryPeriod = time.Duration(config.LeaderElection.RetryPeriod) * time.Second
+
+	if renewDeadline <= 0 || renewDeadline >= duration {
+		renewDeadline = duration * 2 / 3
+	}
+
+	if retryPeriod <= 0 || retryPeriod*2 > renewDeadline {
+		retryPeriod = renewDeadline / 4
+	}
+
+	return duration, renewDeadline, retryPeriod
+
+}
+
+// leadership marks this instance as the leader while the manager runs it, which it only does after winning the
+// node's leader election, so of several instances targeting the same node (e.g. during a rolling upgrade) only one
+// acts at a time. It requests a check right away rather than waiting for the next tick. The manager stops on losing
+// the election, which ends the leadership as well.
+type leadership struct {
+	events chan<- event.GenericEvent
+}
+
+func (l leadership) Start(stop <-chan struct{}) error {
+
+	atomic.StoreInt32(&leading, 1)
+	defer atomic.StoreInt32(&leading, 0)
+
+	if config.LeaderElection.Enabled {
+		log.Println("Acquired the leader election for node", nodeFlag)
+	}
+
+	if sendTick(l.events, stop) {
+		<-stop
+	}
+
+	return nil
+
+}
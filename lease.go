@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// microTimeFormat is the serialization of metav1.MicroTime used by Lease timestamps.
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// lease is a coordination.k8s.io/v1 Lease. The vendored client-go predates the coordination API, so leases are
+// handled as JSON over the REST client.
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int32  `json:"leaseTransitions,omitempty"`
+}
+
+func leasePath(namespace, name string) string {
+
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", namespace)
+
+	if name != "" {
+		path += "/" + name
+	}
+
+	return path
+
+}
+
+// getLease fetches a lease; a nil lease and nil error mean it does not exist.
+func getLease(namespace, name string) (*lease, error) {
+
+	started := time.Now()
+	raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(leasePath(namespace, name)).Do().Raw()
+	observeCall("kubernetes", "GetLease", started, err)
+
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var l lease
+	return &l, json.Unmarshal(raw, &l)
+
+}
+
+// saveLease creates the lease, or updates it when it carries a resourceVersion. Updates fail with a conflict when
+// someone else changed the lease in the meantime.
+func saveLease(l *lease) error {
+
+	l.APIVersion = "coordination.k8s.io/v1"
+	l.Kind = "Lease"
+
+	body, err := json.Marshal(l)
+
+	if err != nil {
+		return err
+	}
+
+	client := kubeClient.CoreV1().RESTClient()
+	started := time.Now()
+
+	if l.Metadata.ResourceVersion == "" {
+		err = client.Post().AbsPath(leasePath(l.Metadata.Namespace, "")).
+			SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+		observeCall("kubernetes", "CreateLease", started, err)
+	} else {
+		err = client.Put().AbsPath(leasePath(l.Metadata.Namespace, l.Metadata.Name)).
+			SetHeader("Content-Type", "application/json").Body(body).Do().Error()
+		observeCall("kubernetes", "UpdateLease", started, err)
+	}
+
+	return err
+
+}
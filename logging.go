@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"log/syslog"
+	"strings"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+// journalWriter sends each log line to the systemd journal with dcc's identifying fields attached.
+type journalWriter struct {
+	fields map[string]string
+}
+
+func (w journalWriter) Write(p []byte) (int, error) {
+	return len(p), journal.Send(strings.TrimRight(string(p), "\n"), journal.PriInfo, w.fields)
+}
+
+// setupLogging redirects the standard logger to the configured backend. Logging stays on stderr when the backend
+// is unknown or unavailable.
+func setupLogging() {
+
+	tag := config.Logging.Tag
+	if tag == "" {
+		tag = "dcc"
+	}
+
+	switch config.Logging.Backend {
+
+	case "", "stderr":
+		return
+
+	case "syslog":
+		writer, err := syslog.Dial(config.Logging.SyslogNetwork, config.Logging.SyslogAddress, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+
+		if err != nil {
+			log.Println("Cannot connect to syslog, logging to stderr:", err.Error())
+			return
+		}
+
+		log.SetFlags(0)
+		log.SetOutput(writer)
+
+	case "journald":
+		if !journal.Enabled() {
+			log.Println("systemd journal is not available, logging to stderr.")
+			return
+		}
+
+		log.SetFlags(0)
+		log.SetOutput(journalWriter{fields: map[string]string{
+			"SYSLOG_IDENTIFIER": tag,
+			"DCC_NODE":          nodeFlag,
+			"DCC_MODE":          modeFlag,
+			"DCC_VERSION":       version,
+		}})
+
+	default:
+		log.Println("Unknown logging backend", config.Logging.Backend, "- logging to stderr.")
+
+	}
+
+}
@@ -3,28 +3,23 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 	"github.com/docker/docker/api/types"
-	docker "github.com/docker/docker/client"
-	corev1 "k8s.io/api/core/v1"
-	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"golang.org/x/net/context"
-	"gopkg.in/yaml.v2"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/api/core/v1"
-	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"github.com/kernelpanek/dcc/pkg/checker"
+	"github.com/kernelpanek/dcc/pkg/kube"
 )
 
 var (
@@ -33,35 +28,16 @@ var (
 	nodeFlag       string
 	nodeReference  *v1.Node
 	modeFlag       string
-	config         = Config{Timing: Timing{CheckInterval: 90, StopTimeout: 30} }
-	kubeClient	   *kubernetes.Clientset
+	roleFlag       string
+	config         = dccconfig.Default()
+	kubeClient	   kubernetes.Interface
+	watchClient    kubernetes.Interface
 	kubeRecorder   record.EventRecorder
-	wg             sync.WaitGroup
 )
 
-type Timing struct {
 
-	CheckInterval uint32 `yaml:"check_interval"`
-
-	StopTimeout uint32 `yaml:"stop_timeout"`
-
-}
-
-type Whitelist struct {
-
-	Images     []string `yaml:"images"`
-
-}
-
-type Config struct {
-
-	Timing Timing `yaml:"timing"`
-
-	Whitelist Whitelist `yaml:"whitelist"`
-
-}
-
-func init() {
+// parseFlags defines the command line flags, with defaults from the environment, and parses them.
+func parseFlags() {
 
 	if home := os.Getenv("HOME"); home != "" {
 		kubeconfigFlag = flag.String("kubeconfig",
@@ -81,272 +57,556 @@ func init() {
 	log.Println("node:", nodeFlag)
 
 	if mode := os.Getenv("MODE"); mode != "" {
-		flag.StringVar(&modeFlag, "mode", os.Getenv("MODE"), "current mode (remove or watch [default])")
+		flag.StringVar(&modeFlag, "mode", os.Getenv("MODE"), "current mode (remove, watch [default] or npd)")
 	} else {
 		flag.StringVar(&modeFlag, "mode", "watch", "current node")
 	}
 
 	log.Println("mode:", modeFlag)
 
+	if role := os.Getenv("ROLE"); role != "" {
+		flag.StringVar(&roleFlag, "role", role, "current role (standalone [default], agent, controller or auditor)")
+	} else {
+		flag.StringVar(&roleFlag, "role", roleStandalone, "current role (standalone [default], agent, controller or auditor)")
+	}
+
+	log.Println("role:", roleFlag)
+
 	flag.StringVar(&contextFlag, "context", "", "context")
 
+	flag.BoolVar(&armedFlag, "i-understand-this-deletes-containers", false, "arm remove mode")
+
 	log.Println("context:", contextFlag)
 
 	flag.Parse()
 
+}
+
+// setup loads the configuration and connects everything a run needs. It is called explicitly from main rather than
+// from init, so nothing reads files or talks to the network merely because the package is loaded. Anything that
+// rules out running at all is returned as an error.
+func setup() error {
+
 	loadConfiguration()
 
-	kubeClient = createK8sClient()
+	// The controller and the auditor work across clusters and have no node of their own.
+	onNode := roleFlag != roleController && roleFlag != roleAuditor
 
-	kubeRecorder = getEventRecorder(kubeClient, nodeFlag, "container-checker")
+	// The node is read first, since its labels may select a profile that changes everything set up below.
+	if onNode {
 
-	nodeReference = getNodeReference().DeepCopy()
+		var err error
 
-	log.Println("config:", config)
+		if kubeClient, err = createK8sClient(time.Duration(config.Timeouts.Kubernetes) * time.Second); err != nil {
+			return err
+		}
 
-}
+		// Watches are long-lived requests, which a client timeout would cut off.
+		if watchClient, err = createK8sClient(0); err != nil {
+			return err
+		}
 
-// loadConfiguration reads the configuration YAML file.
-func loadConfiguration() {
+		setupEventsAPI()
 
-	fileData, err := ioutil.ReadFile("/config/config.yaml")
+		if err := checkPermissions(); err != nil {
+			return err
+		}
+
+		node, err := getNodeReference()
+
+		if err != nil {
+			return err
+		}
+
+		nodeReference = node.DeepCopy()
+
+		if err := validateNodeIdentity(nodeReference); err != nil {
+			return err
+		}
+
+		if applyNodeLabels(nodeReference) {
+			if err := checkPermissions(); err != nil {
+				return err
+			}
+		}
 
-	if err != nil {
-		log.Fatalln(err.Error())
-		panic(err.Error())
 	}
 
-	yaml.Unmarshal(fileData, &config)
+	armRemoveMode()
 
-}
+	validateWhitelist()
+
+	setupLogging()
 
-// compareContainerGroups compares the Docker containers list with the Kubernetes containers list. If an orphan,
-// in the dockerGroup, is found; then it is returned to caller.
-func compareContainerGroups(dockerGroup []types.Container, kubernetesGroup []string) []types.Container {
+	setupEventLimits()
 
-	var orphansFound []types.Container
+	setupDockerLimits()
 
-	for _, container := range dockerGroup {
+	setupNotifiers()
 
-		if !stringInSlice(container.ID, &kubernetesGroup) {
-			log.Println("Orphan Container Found:", container.ID, "(", container.Image, ") Age:", time.Since(time.Unix(container.Created, 0)))
-			orphansFound = append(orphansFound, container)
+	setupReportUpload()
+
+	if roleFlag == roleAgent || roleFlag == roleController {
+		if _, err := controllerTLS(); err != nil {
+			return err
 		}
+	}
 
+	if onNode {
+		kubeRecorder = getEventRecorder(kubeClient, nodeFlag, "container-checker")
+		reportConfigProblem()
 	}
 
-	return orphansFound
+	log.Println("config:", config)
+
+	return nil
 
 }
 
-// createK8sClient connects the application to a Kubernetes cluster.
-func createK8sClient() *kubernetes.Clientset {
+// loadConfiguration reads the configuration YAML file. A missing or invalid file must not crash-loop the whole fleet,
+// so dcc then keeps its built-in defaults and runs report-only until the file can be loaded.
+func loadConfiguration() {
 
-	config, err := rest.InClusterConfig()
+	loaded, err := dccconfig.Read(configPath, config)
 
 	if err != nil {
-		var configOverrides = clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{Server: ""}, Context: clientcmdapi.Context{Cluster: contextFlag}}
+		configProblem = err.Error()
+		log.Println("Cannot load configuration, running report-only with built-in defaults:", configProblem)
+		return
+	}
 
-		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeconfigFlag},
-			&configOverrides).ClientConfig()
+	config = loaded
 
-		if err != nil {
-			log.Fatalln(err.Error())
-			panic(err.Error())
-		}
+}
+
+// createK8sClient connects the application to a Kubernetes cluster. Requests taking longer than the timeout fail,
+// unless it is zero.
+func createK8sClient(timeout time.Duration) (*kubernetes.Clientset, error) {
+	return kube.NewClient(k8sClientOptions(timeout))
+}
+
+// k8sClientOptions returns the options of Kubernetes clients set by the flags and Config.
+func k8sClientOptions(timeout time.Duration) kube.ClientOptions {
+	return kube.ClientOptions{
+		Kubeconfig: *kubeconfigFlag,
+		Context:    contextFlag,
+		QPS:        config.Kubernetes.QPS,
+		Burst:      config.Kubernetes.Burst,
+		Timeout:    timeout,
 	}
+}
+
+// executeCheck performs the core functionality of this application: Look for outstanding docker containers that the
+// Kubernetes API no longer knows about. The check can be limited to the containers of some namespaces. The number of
+// orphans found is returned.
+func executeCheck(namespaces []string) int {
 
-	clientset, err := kubernetes.NewForConfig(config)
+	var failures cycleFailures
+
+	ctx, cancel := cycleContext()
+	defer cancel()
+
+	probeBreaker()
+	defer updateBreaker(&failures)
+
+	// Without the full pod list every container would look orphaned, and remove mode would stop every workload on
+	// the node. Without the container list there is nothing to compare.
+	rt, result, err := checkContainers(ctx, namespaces, &failures)
 
 	if err != nil {
-		log.Println("Error with connecting to cluster:", err.Error())
-		panic(err.Error())
+		abortedCycles++
+		cyclesAborted.Inc()
+		log.Println("Aborting cycle: the pods or the containers on the node could not be listed.")
+		report := cycleReport{Node: nodeFlag, Mode: modeFlag, Time: time.Now(), Failures: failures.list()}
+		reportCycleFailures(report.Failures)
+		publishReport(report)
+		return 0
 	}
 
-	return clientset
-}
+	abortedCycles = 0
 
-// executeCheck performs the core functionality of this application: Look for outstanding docker containers that the
-// Kubernetes API no longer knows about.
-func executeCheck() {
-
-	var dockerChannel = make(chan []types.Container)
-	var k8sChannel = make(chan []string)
-	var k8sLogMessageChannel = make(chan string)
-	var kubernetesContainers []string
-	var dockerContainers []types.Container
-
-	wg.Add(2)
-
-	go func() {
-		kubernetesContainers = <-k8sChannel
-		wg.Done()
-	}()
-
-	go func() {
-		dockerContainers = <-dockerChannel
-		wg.Done()
-	}()
-
-	go func() {
-		for {
-			msg := <-k8sLogMessageChannel
-			sendEvent("DanglingContainer", msg)
+	// On a quiet node most cycles see exactly what the last one saw; those skip everything but the heartbeat.
+	if len(namespaces) == 0 {
+
+		fingerprint := containerSetsFingerprint(result.Containers, result.KnownIDs)
+
+		if unchangedSinceLastCycle(fingerprint) {
+			unchangedCycles.Inc()
+			log.Println("No orphaned containers found, nothing changed since the last cycle.")
+			renewHeartbeatLease(cycleReport{Node: nodeFlag, Mode: modeFlag, Time: time.Now()})
+			return 0
 		}
-	}()
 
-	go getPodContainers(k8sChannel)
-	go getDockerContainers(dockerChannel)
-	wg.Wait()
+		lastFullCycle = cycleSnapshot{
+			fingerprint: fingerprint,
+			clean:       allKnown(result.Containers, result.KnownIDs),
+			at:          time.Now(),
+		}
+
+	}
+
+	observeContainers(result.Checked, len(namespaces) == 0)
+
+	orphans := excludeStaticPods(result.Orphans, result.Checked, &failures)
+	orphans, filtered := filterOrphans(ctx, orphans, &failures)
+	recordSightings(orphans, namespaces)
 
-	orphans := compareContainerGroups(dockerContainers, kubernetesContainers)
+	// Targeted checks after a namespace deletion expect most of their containers to be orphans. Only a full check
+	// sees every orphan on the node, so only it can tell which stop failures are no longer relevant.
+	if len(namespaces) == 0 {
+		checkMassOrphans(orphans, result.Checked, &failures)
+		pruneStopCooldowns(orphans)
+	}
+	logOrphans(orphans)
+
+	var findings []finding
 
 	if len(orphans) > 0 {
-		removeOrReportOrphanContainers(orphans, k8sLogMessageChannel)
+		findings = removeOrReportOrphanContainers(ctx, rt, orphans, filtered, &failures)
 	} else {
 		log.Println("No orphaned containers found.")
 	}
-}
 
-// getDockerContainers connects to the local Docker daemon to retrieve a list of running containers and removes
-// the containers based on the criteria of the whitelist in Config.
-func getDockerContainers(listChannel chan []types.Container) () {
-	cli, err := docker.NewEnvClient()
+	report := cycleReport{Node: nodeFlag, Mode: modeFlag, Time: time.Now(), Findings: findings, Failures: failures.list()}
+	reportCycleFailures(report.Failures)
 
-	if err != nil {
-		log.Println("Cannot connect to Docker daemon.", err.Error())
-	}
+	publishReport(report)
 
-	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{})
+	updateSummary(report)
+	archiveReport(report)
+	exportActions(report)
 
-	if err != nil {
-		log.Println("No running containers found in Docker.", err.Error())
+	// The node-wide state is only known after a full check.
+	if len(namespaces) == 0 {
+		writeReportResource(report)
+		annotateNode(report)
+		setNodeCondition(report)
+		taintNode(report)
+		renewHeartbeatLease(report)
 	}
 
-	var filtered []types.Container
+	return len(orphans)
+}
 
-	for _, c := range containers {
-		remove := false
+// checkContainers connects to the container runtime for the cycle and runs the checker on the node's containers,
+// less the whitelisted ones. A failure to list the containers or the pods is recorded under its stage, unless the
+// listing was cancelled because the other one failed.
+func checkContainers(ctx context.Context, namespaces []string, failures *cycleFailures) (dccruntime.Runtime, checker.Result, error) {
 
-		for _, image := range config.Whitelist.Images {
-			if strings.Contains(c.Image, image) {
-				remove = true
-			}
-		}
+	rt, err := newContainerRuntime()
 
-		if !remove {
-			filtered = append(filtered, c)
-		}
+	if err != nil {
+		log.Println("Cannot connect to Docker daemon.", err.Error())
+		return nil, checker.Result{}, failures.fail("docker-connect", err)
+	}
+
+	options := checkOptions()
+	options.Runtime = rt
+	options.Pods = orchestratorPods{orch: nodeOrchestrator, failures: failures}
+	options.Namespaces = namespaces
+
+	result, err := checker.Run(ctx, options)
 
+	if e, ok := err.(*checker.Error); ok && e.Source == checker.SourceRuntime {
+		log.Println("No running containers found in Docker.", e.Err.Error())
+		return rt, result, failures.fail("docker-list", e.Err)
 	}
 
-	listChannel <- filtered
+	return rt, result, err
+
 }
 
-// getPodContainers connects to the Kubernetes API to retrieve a list of containers running in all pods running on
-// the same node as the Docker daemon.
-func getPodContainers(k8sChannel chan []string) {
+// checkOptions returns the options of the checker set by Config: whitelisted images are never orphans, nor are
+// containers younger than min_age.
+func checkOptions() checker.Options {
+	return checker.Options{
+		Exclude:        whitelisted,
+		MinAge:         time.Duration(config.Sanity.MinAge) * time.Second,
+		Age:            containerAge,
+		PrefixMatching: config.Kubernetes.IDMatching == idMatchPrefix,
+	}
+}
 
-	podList, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+// whitelisted returns true for containers whose image is whitelisted in Config.
+func whitelisted(c types.Container) bool {
 
-	if err != nil {
-		log.Println("Error in listing pods:", err.Error())
+	for _, image := range config.Whitelist.Images {
+		if strings.Contains(c.Image, image) {
+			return true
+		}
 	}
 
-	var containerIDs []string
+	return false
+
+}
 
-	for _, pod := range podList.Items {
+// orchestratorPods is the checker's source of pods: the orchestrator of the node, with its failures recorded for the
+// cycle.
+type orchestratorPods struct {
+	orch     orchestrator
+	failures *cycleFailures
+}
 
-		if pod.Spec.NodeName == nodeFlag {
+func (p orchestratorPods) KnownContainerIDs() ([]string, error) {
+	return getPodContainers(p.orch, p.failures)
+}
 
-			for _, status := range pod.Status.ContainerStatuses {
-				containerID := strings.TrimPrefix(status.ContainerID, "docker://")
-				containerIDs = append(containerIDs, containerID)
-			}
+// getPodContainers retrieves the IDs of the containers the orchestrator knows to run on the node, recording a
+// failure under the stage the orchestrator names.
+func getPodContainers(orch orchestrator, failures *cycleFailures) ([]string, error) {
 
-		}
+	containerIDs, err := orch.KnownContainerIDs()
 
+	if err != nil {
+		stage := "pod-list"
+		if e, ok := err.(stageError); ok {
+			stage = e.stage
+		}
+		return nil, failures.fail(stage, err)
 	}
 
-	k8sChannel <- containerIDs
+	return containerIDs, nil
 
 }
 
-// sendEvent places an event on the recorder.
+// sendEvent places an event on the recorder, subject to the event rate limit.
 func sendEvent(reason, messageFmt string) {
-	kubeRecorder.Event(nodeReference, corev1.EventTypeWarning, reason, messageFmt)
+	if allowEvent(dccEvent{Reason: reason, Message: messageFmt}) {
+		recordEvent(nodeEventReference(), nil, reason, messageFmt)
+	}
+}
+
+// sendAnnotatedEvent places an event carrying machine-readable annotations on the recorder.
+func sendAnnotatedEvent(event dccEvent) {
+	object := event.Object
+	if object == nil {
+		object = nodeEventReference()
+	}
+
+	recordEvent(object, event.Annotations, event.Reason, event.Message)
 }
 
 // getEventRecorder generates a recorder for specific node name and source.
-func getEventRecorder(c *kubernetes.Clientset, nodeName, source string) record.EventRecorder {
+func getEventRecorder(c kubernetes.Interface, nodeName, source string) record.EventRecorder {
 	eventBroadcaster := record.NewBroadcaster()
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: source, Host: nodeName})
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.CoreV1().Events("")})
 	return recorder
 }
 
-func getNodeReference() *v1.Node {
-	node, err := kubeClient.CoreV1().Nodes().Get(nodeFlag, metav1.GetOptions{})
+func getNodeReference() (*v1.Node, error) {
+	var node *v1.Node
+	err := retryAPI("GetNode", func() (err error) {
+		getStarted := time.Now()
+		node, err = kubeClient.CoreV1().Nodes().Get(nodeFlag, metav1.GetOptions{})
+		observeCall("kubernetes", "GetNode", getStarted, err)
+		return err
+	})
 	if err != nil {
-		log.Println("Node information was not retrieved:", err.Error())
-		panic(err)
+		return nil, fmt.Errorf("node information was not retrieved: %s", err.Error())
 	}
-	return node
+	return node, nil
 }
 
-// removeOrphanContainers iterates through the orphan containers and calls Docker ContainerStop on each container with
-// 30 seconds timeout.
-func removeOrReportOrphanContainers(orphans []types.Container, logChannel chan string) {
+// removeOrReportOrphanContainers iterates through the orphan containers. In remove mode it stops each container with
+// its pod's grace period as timeout, several at a time, and defers those a guard vetoes; otherwise it reports them.
+// The action taken on each orphan is returned for notification.
+func removeOrReportOrphanContainers(ctx context.Context, rt dccruntime.Runtime, orphans []types.Container, filtered filterResult, failures *cycleFailures) []finding {
 
+	cli := dockerClientOf(rt)
 
-		cli, err := docker.NewEnvClient()
+	var findings []finding
 
-		if err != nil {
-			log.Println("Cannot connect to Docker daemon.", err.Error())
+	var guards []removalGuard
+
+	// Agents also need remove mode armed on their own node; the controller's answer is one more guard on top.
+	removing := modeFlag == modeRemove
+
+	// Without a runtime there is no stopping anything; the orphans are still reported.
+	if removing && rt == nil {
+		guards = []removalGuard{func(types.Container, podOwner) string {
+			return "Docker daemon unavailable"
+		}}
+	} else if removing {
+		guards = removalGuards(ctx, rt, failures)
+	}
+
+	budget := newCycleBudget()
+
+	pool := newStopPool(ctx, rt, cli)
+
+	for i, c := range orphans {
+
+		heartbeat()
+
+		if isShuttingDown() {
+			log.Println("Shutting down, leaving", len(orphans)-i, "orphans for the next run.")
+			break
+		}
+
+		// A cycle can outlast the leader lease; once another instance may have taken over, this one stops acting.
+		if !isLeader() {
+			log.Println("Lost leadership, leaving", len(orphans)-i, "orphans to the new leader.")
+			break
+		}
+
+		// Past the cycle's deadline every further call would fail at once; the rest wait for the next cycle.
+		if ctx.Err() != nil {
+			log.Println("Cycle timed out, leaving", len(orphans)-i, "orphans for the next cycle.")
+			failures.add("cycle-timeout", ctx.Err())
+			break
+		}
+
+		budget.pace()
+
+		owner := inferPodOwner(c)
+		rule, eventReason, subject := ruleUnknownToKubernetes, "DanglingContainer", "Dangling container"
+
+		// A terminating pod's containers are left to the kubelet until its grace period and the slack have passed;
+		// after that the teardown is stuck.
+		if owner.State == podStateTerminating {
+			if time.Now().Before(owner.TeardownDeadline.Add(time.Duration(config.Timing.TerminatingSlack) * time.Second)) {
+				continue
+			}
+			rule, eventReason, subject = ruleStuckTeardown, "StuckTeardown", "Container of stuck terminating pod"
 		}
 
-		var stopTimeout= time.Duration(config.Timing.StopTimeout) * time.Second
+		rule = filtered.rule(c.ID, rule)
+
+		logTail := containerLogTail(ctx, cli, c.ID)
+		from := ""
+		if owner.Name != "" {
+			from = fmt.Sprintf(" from pod %s (%s)", owner, owner.State)
+		}
+
+		if removing {
+
+			reason := vetoRemoval(guards, c, owner)
+			down := false
+
+			if reason == "" {
+				reason = filtered.veto(c.ID)
+			}
+
+			// Guards judge the cycle's data; the orphan is checked against fresh data only once everything else agrees.
+			if reason == "" {
+				reason, down = reverifyOrphan(ctx, rt, c)
+			}
+
+			// The controller's reservation is only kept for orphans handed to the stop pool.
+			if reason != "" || down {
+				releaseToController(c.ID)
+			}
 
+			// Nothing to stop, and nothing to report: the container is no longer running.
+			if down {
+				log.Println("Container already down:", c.ID, "(", c.Image, ")", "Reason:", reason)
+				alreadyDown.Inc()
+				clearStopCooldown(c.ID)
+				continue
+			}
 
-	for _, c := range orphans {
+			s := &plannedStop{c: c, owner: owner, rule: rule, eventReason: eventReason, subject: subject, from: from, logTail: logTail}
 
-		if modeFlag == "remove" {
+			if reason != "" {
+				findings = append(findings, reportDeferred(s, reason))
+				continue
+			}
 
-			log.Println("Stopping container:", c)
-			cli.ContainerStop(context.Background(), c.ID, &stopTimeout)
-			logChannel <- fmt.Sprintf("Dangling container stopped: %s (%s)", c.ID, c.ImageID)
+			pool.submit(s)
 
 		} else {
 
-			log.Println("Observing dangling container:", c)
-			logChannel <- fmt.Sprintf("Dangling container found: %s (%s)", c.ID, c.ImageID)
+			if shouldLogSighting(c.ID) {
+				log.Println("Observing dangling container:", c.ID, "(", c.Image, ")", "Pod:", owner, "State:", owner.State)
+			}
+			nodeOrchestrator.Event(dccEvent{
+				Reason:      eventReason,
+				Message:     fmt.Sprintf("%s found: %s (%s)%s", subject, c.ID, c.ImageID, from),
+				Annotations: containerAnnotations(c, owner, rule, outcomeReported),
+				Object:      eventObjectFor(owner),
+			})
+			f := newFinding(c, owner, rule, outcomeReported, logTail)
+			recordDanglingContainer(f, nil)
+			findings = append(findings, f)
 
 		}
 
 	}
 
-}
+	for _, s := range pool.wait() {
+		if f, ok := reportStop(s, failures); ok {
+			findings = append(findings, f)
+		}
+	}
 
-// stringInSlice return true if list contains the string.
-func stringInSlice(a string, list *[]string) bool {
+	return findings
 
-	for _, b := range *list {
+}
 
-		if strings.Contains(b, a) {
-			return true
-		}
+// checkDeadline records a cycle that ran longer than the check interval.
+func checkDeadline(elapsed, interval time.Duration) {
 
+	if elapsed <= interval {
+		return
 	}
 
-	return false
+	deadlineMisses.Inc()
+	log.Println("Cycle exceeded check interval:", elapsed, ">", interval)
+	sendEvent("CycleDeadlineMissed", fmt.Sprintf("Check cycle took %s, longer than the %s check interval", elapsed, interval))
+
 }
 
 func main() {
 
-	for {
-		executeCheck()
-		time.Sleep(time.Duration(config.Timing.CheckInterval) * time.Second)
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	log.Println(versionString())
+
+	parseFlags()
+
+	if err := setup(); err != nil {
+		log.Fatalln("Refusing to run:", err.Error())
+	}
+
+	if modeFlag == modeNPD {
+		os.Exit(runNPDPlugin())
+	}
+
+	go serveMetrics()
+
+	if roleFlag == roleController {
+		runController()
+		return
+	}
+
+	if roleFlag == roleAuditor {
+		runAudit()
+		return
+	}
+
+	// Idle rather than exit, so a DaemonSet can target every node without crash-looping on some.
+	if reason := unsupportedNode(nodeReference); reason != "" {
+		log.Println("Not checking this node:", reason)
+		select {}
+	}
+
+	go watchSignals()
+	go retryConfiguration()
+	go dispatcher.run()
+	go runWatchdog()
+	go runJiraChecks()
+
+	reportWhitelistMatches()
+
+	startPodInformer()
+	watchNamespaceDeletions()
+
+	if err := runManager(); err != nil {
+		log.Fatalln("Check loop stopped:", err.Error())
 	}
 
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/kernelpanek/dcc/pkg/checker"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testID returns a full-length container ID starting with the given characters.
+func testID(start string) string {
+	return start + strings.Repeat("0", 64-len(start))
+}
+
+func TestOrphanDetection(t *testing.T) {
+
+	running, orphan, young, whitelisted := testID("a1"), testID("b2"), testID("c3"), testID("d4")
+
+	youngContainer := testContainer(young)
+	youngContainer.Created = time.Now().Unix()
+
+	whitelistedContainer := testContainer(whitelisted)
+	whitelistedContainer.Image = "k8s.gcr.io/pause:3.1"
+
+	rt := newFakeRuntime(testContainer(running), testContainer(orphan), youngContainer, whitelistedContainer)
+	orch := &fakeOrchestrator{known: []string{running}}
+	defer useFakes(rt, orch)()
+
+	config.Sanity.MinAge = 180
+	config.Whitelist.Images = []string{"pause"}
+
+	var failures cycleFailures
+
+	_, result, err := checkContainers(context.Background(), nil, &failures)
+	if err != nil {
+		t.Fatalf("checkContainers: %v", err)
+	}
+
+	var ids []string
+	for _, c := range result.Orphans {
+		ids = append(ids, c.ID)
+	}
+
+	if !reflect.DeepEqual(ids, []string{orphan}) {
+		t.Errorf("orphans = %v, want only %s", ids, orphan)
+	}
+
+}
+
+func TestOrphanDetectionListFailures(t *testing.T) {
+
+	failingRuntime := newFakeRuntime(testContainer(testID("a1")))
+	failingRuntime.listErr = errors.New("daemon unavailable")
+
+	tests := []struct {
+		name  string
+		rt    *fakeRuntime
+		orch  *fakeOrchestrator
+		stage string
+	}{
+		{"runtime", failingRuntime, &fakeOrchestrator{known: []string{testID("a1")}}, "docker-list"},
+		{"orchestrator", newFakeRuntime(testContainer(testID("a1"))),
+			&fakeOrchestrator{err: stageError{stage: "pod-cache", err: errors.New("cache not synced")}}, "pod-cache"},
+	}
+
+	for _, test := range tests {
+
+		restore := useFakes(test.rt, test.orch)
+
+		var failures cycleFailures
+		_, result, err := checkContainers(context.Background(), nil, &failures)
+
+		restore()
+
+		if err == nil || len(result.Orphans) != 0 {
+			t.Errorf("%s: checkContainers = %v, %v, want an error and no orphans", test.name, result.Orphans, err)
+		}
+
+		if !failures.failed(test.stage) {
+			t.Errorf("%s: failures = %v, want %s", test.name, failures.list(), test.stage)
+		}
+
+	}
+
+}
+
+// A container whose ID only appears in an init container status, such as a native sidecar or a finished init
+// container the runtime still holds, belongs to its pod and is no orphan.
+func TestInitAndSidecarContainersAreNoOrphans(t *testing.T) {
+
+	app, sidecar, finishedInit, orphan := testID("a1"), testID("b2"), testID("c3"), testID("d4")
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: nodeFlag},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{
+				{Name: "migrate", ContainerID: "docker://" + finishedInit},
+				{Name: "proxy", ContainerID: "docker://" + sidecar},
+			},
+			ContainerStatuses: []v1.ContainerStatus{{Name: "web", ContainerID: "docker://" + app}},
+		},
+	}
+
+	savedClient := kubeClient
+	defer func() { kubeClient = savedClient }()
+
+	kubeClient = fake.NewSimpleClientset(pod)
+
+	known, err := kubernetesOrchestrator{}.KnownContainerIDs()
+	if err != nil {
+		t.Fatalf("KnownContainerIDs: %v", err)
+	}
+
+	containers := []types.Container{testContainer(app), testContainer(sidecar), testContainer(finishedInit), testContainer(orphan)}
+
+	var ids []string
+	for _, c := range checker.Compare(containers, known, checkOptions()) {
+		ids = append(ids, c.ID)
+	}
+
+	if !reflect.DeepEqual(ids, []string{orphan}) {
+		t.Errorf("orphans = %v, want only %s", ids, orphan)
+	}
+
+}
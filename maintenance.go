@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a daily span of UTC time, optionally limited to some weekdays, in which removals may happen.
+// A span ending before it starts runs past midnight into the next day.
+type maintenanceWindow struct {
+	days  map[time.Weekday]bool
+	start time.Duration
+	end   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseMaintenanceWindows parses a comma-separated list of windows such as "Sat,Sun 00:00-06:00, 22:00-02:00".
+// Days apply to the window that follows them; a window without days applies every day.
+func parseMaintenanceWindows(value string) ([]maintenanceWindow, error) {
+
+	var windows []maintenanceWindow
+	days := map[time.Weekday]bool{}
+
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+
+		if day, ok := weekdays[strings.ToLower(field)]; ok {
+			days[day] = true
+			continue
+		}
+
+		span := strings.SplitN(field, "-", 2)
+
+		if len(span) != 2 {
+			return nil, fmt.Errorf("invalid maintenance window %q", field)
+		}
+
+		start, err := parseClock(span[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseClock(span[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, maintenanceWindow{days: days, start: start, end: end})
+		days = map[time.Weekday]bool{}
+
+	}
+
+	if len(days) > 0 {
+		return nil, fmt.Errorf("maintenance days without a time span in %q", value)
+	}
+
+	return windows, nil
+
+}
+
+// parseClock parses HH:MM into the time since midnight.
+func parseClock(value string) (time.Duration, error) {
+
+	t, err := time.Parse("15:04", value)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+
+}
+
+// contains returns true if the time falls inside the window.
+func (w maintenanceWindow) contains(now time.Time) bool {
+
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	clock := now.Sub(midnight)
+	day := now.Weekday()
+
+	if w.end <= w.start && clock < w.end {
+		// Past midnight in a window that started the day before.
+		day = (day + 6) % 7
+		clock += 24 * time.Hour
+	}
+
+	end := w.end
+	if end <= w.start {
+		end += 24 * time.Hour
+	}
+
+	return (len(w.days) == 0 || w.days[day]) && clock >= w.start && clock < end
+
+}
+
+// maintenanceVeto returns why removals must wait for the maintenance windows in the node annotation. Nodes without
+// the annotation are not restricted; an annotation that cannot be parsed holds all removals.
+func maintenanceVeto(annotations map[string]string, now time.Time) string {
+
+	key := config.Maintenance.Annotation
+	value, ok := annotations[key]
+
+	if key == "" || !ok {
+		return ""
+	}
+
+	windows, err := parseMaintenanceWindows(value)
+
+	if err != nil {
+		return "invalid " + key + " node annotation: " + err.Error()
+	}
+
+	for _, w := range windows {
+		if w.contains(now) {
+			return ""
+		}
+	}
+
+	return "outside the maintenance windows " + value
+
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// deletedNamespacesCheck is the namespace of the request for a check of the recently deleted namespaces only. The
+// request for a full check has none.
+const deletedNamespacesCheck = "deleted-namespaces"
+
+var (
+	// cycleMutex is held while a cycle runs, so shutdown can wait for the cycle in progress.
+	cycleMutex sync.Mutex
+
+	// cycleStarted is the unix time in nanoseconds at which the cycle in progress started, or 0 between cycles.
+	cycleStarted int64
+)
+
+// fullCheckRequest is the request for a full check of the node.
+func fullCheckRequest() reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeFlag}}
+}
+
+// namespacesCheckRequest is the request for a check of the containers of recently deleted namespaces.
+func namespacesCheckRequest() reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: deletedNamespacesCheck, Name: nodeFlag}}
+}
+
+// runManager runs the check loop on a controller-runtime manager until a shutdown is requested or the manager fails,
+// e.g. because this instance lost the node's leader election. Cycles run in the reconciler of a controller fed by a
+// ticker and by pod, node and namespace events; its work queue serializes them and collapses triggers arriving during
+// a cycle into one request. The cycle in progress is waited for before shutting down.
+func runManager() error {
+
+	restConfig, err := kube.NewConfig(k8sClientOptions(0))
+
+	if err != nil {
+		return err
+	}
+
+	options := manager.Options{
+		// serveMetrics exposes the same registry, with the agent status, for every role.
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: config.Probes.Address,
+	}
+
+	if config.LeaderElection.Enabled {
+		duration, renewDeadline, retryPeriod := leaderTimings()
+		options.LeaderElection = true
+		options.LeaderElectionNamespace = config.LeaderElection.Namespace
+		options.LeaderElectionID = "dcc-leader-" + nodeFlag
+		options.LeaseDuration = &duration
+		options.RenewDeadline = &renewDeadline
+		options.RetryPeriod = &retryPeriod
+	}
+
+	mgr, err := manager.New(restConfig, options)
+
+	if err != nil {
+		return fmt.Errorf("cannot create the manager: %s", err.Error())
+	}
+
+	if err := addProbes(mgr); err != nil {
+		return err
+	}
+
+	ticks := make(chan event.GenericEvent)
+
+	if err := mgr.Add(cycleTicker{events: ticks}); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(leadership{events: ticks}); err != nil {
+		return err
+	}
+
+	if err := addCycleController(mgr, ticks); err != nil {
+		return err
+	}
+
+	err = mgr.Start(shutdownRequested)
+	atomic.StoreInt32(&leading, 0)
+
+	if err != nil {
+		requestShutdown("Manager stopped: " + err.Error())
+	}
+
+	// The cycle in progress stops before its next removal.
+	cycleMutex.Lock()
+	shutdown()
+
+	return err
+
+}
+
+// addProbes adds the health probes. The agent is ready once the pod cache has synced, and alive while the watchdog
+// finds no breach, if it is configured to exit on one; without exit_on_breach a breach only raises an event.
+func addProbes(mgr manager.Manager) error {
+
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+
+	if config.Watchdog.Interval > 0 && config.Watchdog.ExitOnBreach {
+		err := mgr.AddHealthzCheck("watchdog", func(*http.Request) error {
+			if breaches := watchdogBreaches(); len(breaches) > 0 {
+				return fmt.Errorf("%s", strings.Join(breaches, "; "))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return mgr.AddReadyzCheck("pod-cache", func(*http.Request) error {
+		if config.Kubernetes.UseInformer && !podCacheReady() {
+			return fmt.Errorf("pod cache not synced")
+		}
+		return nil
+	})
+
+}
+
+// addCycleController adds the controller running the cycles. Besides the ticks, it checks soon after pods on the
+// node were deleted, when the node is cordoned or uncordoned, when its pause or maintenance annotation changes, and
+// after namespaces were deleted. The pods and the namespaces come from dcc's own informers, which only hold what it
+// needs, rather than from the manager's cluster-wide cache.
+func addCycleController(mgr manager.Manager, ticks <-chan event.GenericEvent) error {
+
+	c, err := crcontroller.New("dcc", mgr, crcontroller.Options{Reconciler: cycleReconciler{}})
+
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Channel{Source: ticks}, handler.Funcs{
+		GenericFunc: func(_ event.GenericEvent, q workqueue.RateLimitingInterface) {
+			q.Add(fullCheckRequest())
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if podInformer != nil && config.Kubernetes.DeletionCheckDelay > 0 {
+		err = c.Watch(&source.Informer{Informer: podInformer}, handler.Funcs{
+			DeleteFunc: func(_ event.DeleteEvent, q workqueue.RateLimitingInterface) {
+				schedulePodDeletionCheck(q)
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if namespaceInformer != nil {
+		err = c.Watch(&source.Informer{Informer: namespaceInformer}, handler.Funcs{
+			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+				rememberDeletedNamespace(e.Meta.GetName())
+				q.Add(namespacesCheckRequest())
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	listWatch := cache.NewListWatchFromClient(watchClient.CoreV1().RESTClient(), "nodes", v1.NamespaceAll,
+		fields.OneTermEqualSelector("metadata.name", nodeFlag))
+	nodeInformer := cache.NewSharedIndexInformer(listWatch, &v1.Node{}, 0, cache.Indexers{})
+
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		nodeInformer.Run(stop)
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Informer{Informer: nodeInformer}, handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			if nodeStateChanged(e.ObjectOld, e.ObjectNew) {
+				log.Println("Checking after a change of the node's state.")
+				q.Add(fullCheckRequest())
+			}
+		},
+	})
+
+}
+
+// nodeStateChanged returns true if an update of the Node changed what decides whether removals may go ahead. Status
+// updates and dcc's own annotations and taints do not count, or every cycle would trigger the next.
+func nodeStateChanged(oldObject, newObject runtime.Object) bool {
+
+	old, ok := oldObject.(*v1.Node)
+	node, isNode := newObject.(*v1.Node)
+
+	if !ok || !isNode {
+		return false
+	}
+
+	if old.Spec.Unschedulable != node.Spec.Unschedulable {
+		return true
+	}
+
+	for _, key := range []string{annotationPaused, config.Maintenance.Annotation} {
+		if key != "" && old.Annotations[key] != node.Annotations[key] {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// cycleReconciler runs the check cycles. The controller runs one reconcile at a time, so cycles never overlap.
+type cycleReconciler struct{}
+
+// Reconcile runs a full check, or a check of the recently deleted namespaces, and asks to be run again when an
+// aborted cycle is due to be retried, a drained node to be cleaned up again or a deleted namespace to be checked
+// again.
+func (cycleReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+
+	cycleMutex.Lock()
+	defer cycleMutex.Unlock()
+
+	if isShuttingDown() || !isLeader() {
+		return reconcile.Result{}, nil
+	}
+
+	var namespaces []string
+
+	if request.Namespace == deletedNamespacesCheck {
+
+		if namespaces = recentlyDeletedNamespaces(); len(namespaces) == 0 {
+			return reconcile.Result{}, nil
+		}
+
+		log.Println("Checking recently deleted namespaces:", namespaces)
+
+	}
+
+	runCycle(namespaces)
+
+	recheck := drainCheckAfter()
+
+	if request.Namespace == deletedNamespacesCheck {
+		recheck = namespaceCheckAfter()
+	}
+
+	return reconcile.Result{RequeueAfter: sooner(abortRetryAfter(), recheck)}, nil
+
+}
+
+// sooner returns the shorter of two delays, where 0 is no delay at all rather than the shortest.
+func sooner(a, b time.Duration) time.Duration {
+
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+
+	return a
+
+}
+
+// runCycle runs a cycle and records how it went.
+func runCycle(namespaces []string) {
+
+	started := time.Now()
+
+	atomic.StoreInt64(&cycleStarted, started.UnixNano())
+	defer atomic.StoreInt64(&cycleStarted, 0)
+
+	heartbeat()
+
+	orphans := executeCheck(namespaces)
+	cyclesRun.Add(1)
+	elapsed := time.Since(started)
+	recordCycleStatus(started, elapsed, orphans)
+	cycleDuration.Observe(elapsed.Seconds())
+	checkDeadline(elapsed, time.Duration(config.Timing.CheckInterval)*time.Second)
+
+}
+
+// cycleTicker requests a full check every check interval. It runs on every instance, so a standby one keeps the
+// watchdog's heartbeat going, but only the leader's ticks reach the controller. The heartbeat is left to the cycle
+// while one runs, so a stuck cycle shows as a stalled loop. When skip_missed_ticks is set, a tick arriving while a
+// cycle runs past the check interval is dropped, so the next cycle waits for a full interval instead of starting as
+// soon as the slow one ends.
+type cycleTicker struct {
+	events chan<- event.GenericEvent
+}
+
+func (cycleTicker) NeedLeaderElection() bool {
+	return false
+}
+
+func (t cycleTicker) Start(stop <-chan struct{}) error {
+
+	interval := time.Duration(config.Timing.CheckInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return nil
+		}
+
+		if started := atomic.LoadInt64(&cycleStarted); started == 0 {
+			heartbeat()
+		} else if config.Timing.SkipMissedTicks && time.Since(time.Unix(0, started)) > interval {
+			log.Println("Skipping the next tick after deadline miss.")
+			continue
+		}
+
+		if !isLeader() {
+			log.Println("Standing by: another instance holds the leader lease for this node.")
+			continue
+		}
+
+		if !sendTick(t.events, stop) {
+			return nil
+		}
+
+	}
+
+}
+
+// sendTick passes a tick to the controller, returning false if the manager stopped first.
+func sendTick(events chan<- event.GenericEvent, stop <-chan struct{}) bool {
+
+	tick := event.GenericEvent{Meta: &metav1.ObjectMeta{Name: nodeFlag}, Object: nodeReference}
+
+	select {
+	case events <- tick:
+		return true
+	case <-stop:
+		return false
+	}
+
+}
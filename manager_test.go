@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeStateChanged(t *testing.T) {
+
+	node := func(unschedulable bool, annotations map[string]string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a", Annotations: annotations},
+			Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		old, new *v1.Node
+		want     bool
+	}{
+		{"unchanged", node(false, nil), node(false, nil), false},
+		{"cordoned", node(false, nil), node(true, nil), true},
+		{"paused", node(false, nil), node(false, map[string]string{annotationPaused: "true"}), true},
+		{"other annotation", node(false, nil), node(false, map[string]string{"example.com/owner": "a"}), false},
+	}
+
+	for _, test := range tests {
+		if got := nodeStateChanged(test.old, test.new); got != test.want {
+			t.Errorf("%s: nodeStateChanged() = %t, want %t", test.name, got, test.want)
+		}
+	}
+
+}
+
+func TestSooner(t *testing.T) {
+
+	tests := []struct {
+		a, b, want time.Duration
+	}{
+		{0, 0, 0},
+		{0, time.Minute, time.Minute},
+		{time.Minute, 0, time.Minute},
+		{time.Minute, time.Second, time.Second},
+		{time.Second, time.Minute, time.Second},
+	}
+
+	for _, test := range tests {
+		if got := sooner(test.a, test.b); got != test.want {
+			t.Errorf("sooner(%s, %s) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	cycleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "dcc",
+		Name:      "cycle_duration_seconds",
+		Help:      "Time taken by a complete check cycle.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+	})
+
+	deadlineMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "cycle_deadline_misses_total",
+		Help:      "Number of check cycles that ran longer than the configured check interval.",
+	})
+
+	apiCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dcc",
+		Name:      "api_call_duration_seconds",
+		Help:      "Latency of calls to the Docker daemon and the Kubernetes API.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"api", "call"})
+
+	apiCallErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "api_call_errors_total",
+		Help:      "Number of failed calls to the Docker daemon and the Kubernetes API.",
+	}, []string{"api", "call"})
+
+	apiCallTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "api_call_timeouts_total",
+		Help:      "Number of calls to the Docker daemon and the Kubernetes API that ran out of time.",
+	}, []string{"api", "call"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(cycleDuration, deadlineMisses, apiCallDuration, apiCallErrors, apiCallTimeouts)
+}
+
+// observeCall records the latency and outcome of an external API call that began at started.
+func observeCall(api, call string, started time.Time, err error) {
+
+	apiCallDuration.WithLabelValues(api, call).Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		apiCallErrors.WithLabelValues(api, call).Inc()
+	}
+
+	if timedOut(err) {
+		apiCallTimeouts.WithLabelValues(api, call).Inc()
+	}
+
+}
+
+// serveMetrics exposes the Prometheus metrics and the agent status on the configured address. An empty address
+// disables the listener.
+func serveMetrics() {
+
+	if config.Metrics.Address == "" {
+		return
+	}
+
+	http.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	http.HandleFunc("/status", serveStatus)
+
+	log.Println("Serving metrics on", config.Metrics.Address)
+
+	if err := http.ListenAndServe(config.Metrics.Address, nil); err != nil {
+		log.Println("Metrics listener stopped:", err.Error())
+	}
+
+}
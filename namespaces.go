@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	// namespaceInformer watches the namespaces of the cluster. It is nil unless namespace deletions are checked for.
+	namespaceInformer cache.SharedIndexInformer
+
+	namespaceMutex    sync.Mutex
+	deletedNamespaces = map[string]time.Time{}
+)
+
+// watchNamespaceDeletions watches namespaces so containers left behind by mass deletions, when the kubelet's
+// teardown most often leaves stragglers, are checked for sooner than the next regular cycle. The check controller
+// handles the deletions.
+func watchNamespaceDeletions() {
+
+	if config.NamespaceDeletion.Window == 0 {
+		return
+	}
+
+	listWatch := cache.NewListWatchFromClient(watchClient.CoreV1().RESTClient(), "namespaces", v1.NamespaceAll, fields.Everything())
+
+	namespaceInformer = cache.NewSharedIndexInformer(listWatch, &v1.Namespace{}, 0, cache.Indexers{})
+
+	go namespaceInformer.Run(make(chan struct{}))
+
+}
+
+// rememberDeletedNamespace records a deleted namespace for targeted checks until its window has passed.
+func rememberDeletedNamespace(name string) {
+
+	namespaceMutex.Lock()
+	deletedNamespaces[name] = time.Now().Add(time.Duration(config.NamespaceDeletion.Window) * time.Second)
+	namespaceMutex.Unlock()
+
+	log.Println("Namespace deleted:", name)
+
+}
+
+// recentlyDeletedNamespaces returns the namespaces deleted within the window, forgetting older ones.
+func recentlyDeletedNamespaces() []string {
+
+	namespaceMutex.Lock()
+	defer namespaceMutex.Unlock()
+
+	var namespaces []string
+	now := time.Now()
+
+	for name, until := range deletedNamespaces {
+		if now.After(until) {
+			delete(deletedNamespaces, name)
+			continue
+		}
+		namespaces = append(namespaces, name)
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces
+
+}
+
+// namespaceCheckAfter returns the namespace deletion interval while a recently deleted namespace is still within its
+// window, after which its containers are checked again, and 0 otherwise.
+func namespaceCheckAfter() time.Duration {
+
+	if config.NamespaceDeletion.Interval == 0 || len(recentlyDeletedNamespaces()) == 0 {
+		return 0
+	}
+
+	return time.Duration(config.NamespaceDeletion.Interval) * time.Second
+
+}
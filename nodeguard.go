@@ -0,0 +1,134 @@
+package main
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationPaused on the Node stops dcc from removing containers there while it keeps reporting.
+const annotationPaused = annotationPrefix + "paused"
+
+// Policies for removals on a cordoned node.
+const (
+	drainPolicyHold       = "hold"
+	drainPolicyAggressive = "aggressive"
+)
+
+// nodeDrained is true while the node is cordoned and its workloads are gone, as found by the latest cycle.
+var nodeDrained bool
+
+// nodeGuard defers removals according to the current state of the Node, read once per cycle. If the Node cannot be
+// read, every removal is deferred, since it may carry a pause.
+func nodeGuard(failures *cycleFailures) removalGuard {
+
+	var node *v1.Node
+	err := retryAPI("GetNode", func() (err error) {
+		started := time.Now()
+		node, err = kubeClient.CoreV1().Nodes().Get(nodeFlag, metav1.GetOptions{})
+		observeCall("kubernetes", "GetNode", started, err)
+		return err
+	})
+
+	if err != nil {
+		failures.add("node-get", err)
+		return func(types.Container, podOwner) string {
+			return "node state unavailable"
+		}
+	}
+
+	reason := nodeVeto(node)
+
+	if reason == "" {
+		reason = drainVeto(node, failures)
+	}
+
+	return func(types.Container, podOwner) string {
+		return reason
+	}
+
+}
+
+// nodeVeto returns why the Node's state rules out removals, or an empty string if it does not.
+func nodeVeto(node *v1.Node) string {
+
+	if node.Annotations[annotationPaused] == "true" {
+		return "removals paused by the " + annotationPaused + " node annotation"
+	}
+
+	if reason := maintenanceVeto(node.Annotations, time.Now()); reason != "" {
+		return reason
+	}
+
+	return kubeletVeto(node, time.Now())
+
+}
+
+// drainVeto holds removals on a cordoned node according to the drain policy: always with hold, and with aggressive
+// only until the drain has evicted every workload, since containers may be mid-eviction until then.
+func drainVeto(node *v1.Node, failures *cycleFailures) string {
+
+	policy := config.Drain.Policy
+	nodeDrained = false
+
+	if !node.Spec.Unschedulable || policy == "" {
+		return ""
+	}
+
+	if policy == drainPolicyHold {
+		return "node is cordoned"
+	}
+
+	pods, err := nodePods()
+
+	if err != nil {
+		failures.add("drain-pods", err)
+		return "node is cordoned and its pods are unavailable"
+	}
+
+	for _, pod := range pods {
+		if !drainExempt(pod) {
+			return "node is draining"
+		}
+	}
+
+	nodeDrained = true
+
+	return ""
+
+}
+
+// drainExempt returns true for pods a drain leaves behind: DaemonSet and mirror pods, and pods that have finished.
+func drainExempt(pod *v1.Pod) bool {
+
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return true
+	}
+
+	if _, mirror := pod.Annotations[annotationMirrorPod]; mirror {
+		return true
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// drainCheckAfter returns the drain interval while aggressive cleanup of a drained node is due, after which the node
+// is checked again, and 0 otherwise.
+func drainCheckAfter() time.Duration {
+
+	if config.Drain.Policy != drainPolicyAggressive || !nodeDrained || config.Drain.Interval == 0 {
+		return 0
+	}
+
+	return time.Duration(config.Drain.Interval) * time.Second
+
+}
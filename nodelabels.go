@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/core/v1"
+)
+
+// Node labels that tailor dcc to the node pool it runs on.
+const (
+	labelNodeMode    = annotationPrefix + "mode"
+	labelNodeProfile = annotationPrefix + "profile"
+)
+
+// applyNodeLabels tailors the configuration to the node from its labels, so one DaemonSet can serve heterogeneous
+// pools: the profile label overlays the named profile on the configuration, and the mode label can hold the node
+// in watch mode. Labels can only ever turn remove mode off; with remove_requires_label set, remove mode is only
+// kept on nodes labeled with mode remove. It returns true if a profile was applied.
+func applyNodeLabels(node *v1.Node) bool {
+
+	applied := false
+
+	if name := node.Labels[labelNodeProfile]; name != "" {
+
+		profile, ok := config.Profiles[name]
+
+		// The profile is applied to a copy, so a profile that fails halfway leaves the base configuration intact.
+		profiled := config
+
+		if !ok {
+			log.Println("Node selects unknown profile", name+"; using the base configuration.")
+		} else if data, err := yaml.Marshal(profile); err != nil {
+			log.Println("Cannot apply profile", name+":", err.Error())
+		} else if err := yaml.Unmarshal(data, &profiled); err != nil {
+			log.Println("Cannot apply profile", name+":", err.Error())
+		} else {
+			config = profiled
+			log.Println("Applied profile", name, "selected by node label.")
+			applied = true
+		}
+
+	}
+
+	if modeFlag == "remove" {
+
+		label := node.Labels[labelNodeMode]
+
+		if label == "watch" || config.NodeLabels.RemoveRequiresLabel && label != "remove" {
+			log.Println("Node label", labelNodeMode+"="+label, "holds this node in watch mode.")
+			modeFlag = "watch"
+		}
+
+	}
+
+	return applied
+
+}
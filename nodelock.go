@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/docker/docker/api/types"
+)
+
+// nodeLock is the open lock file while this process holds the node lock. The lock is held until the process exits,
+// when the kernel releases it, however the process ends.
+var nodeLock *os.File
+
+// acquireNodeLock takes the node lock unless this process already holds it, so two dcc processes on the same node,
+// such as the old and new pod of a botched DaemonSet update or a manual debugging run, never stop containers of the
+// same daemon at once. It returns why removals must wait, or an empty string once the lock is held or when no lock
+// file is configured. The lock is an exclusive flock on a file, which must be on a hostPath volume shared by every
+// dcc pod of the node to mean anything.
+func acquireNodeLock() string {
+
+	path := config.Lock.File
+
+	if path == "" || nodeLock != nil {
+		return ""
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "cannot create node lock directory: " + err.Error()
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+
+	if err != nil {
+		return "cannot open node lock: " + err.Error()
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+
+		file.Close()
+
+		if err == syscall.EWOULDBLOCK {
+			return "another dcc process holds the node lock " + path
+		}
+
+		return "cannot take node lock: " + err.Error()
+
+	}
+
+	log.Println("Holding node lock", path)
+	nodeLock = file
+
+	return ""
+
+}
+
+// nodeLockGuard defers every removal while another process holds the node lock.
+func nodeLockGuard(reason string) removalGuard {
+
+	return func(c types.Container, owner podOwner) string {
+		return reason
+	}
+
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Node annotations summarising the latest cycle.
+const (
+	annotationLastScan       = annotationPrefix + "last-scan"
+	annotationOrphansFound   = annotationPrefix + "orphans-found"
+	annotationOrphansRemoved = annotationPrefix + "orphans-removed"
+)
+
+// patchNode applies a JSON merge patch to this node.
+func patchNode(patch interface{}) error {
+
+	data, err := json.Marshal(patch)
+
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	_, err = kubeClient.CoreV1().Nodes().Patch(nodeFlag, types.MergePatchType, data)
+	observeCall("kubernetes", "PatchNode", started, err)
+
+	return err
+
+}
+
+// annotateNode records the outcome of the cycle as annotations on the node, so a fleet overview is one
+// `kubectl get nodes -o custom-columns` away.
+func annotateNode(report cycleReport) {
+
+	if !config.NodeStatus.Annotations {
+		return
+	}
+
+	err := patchNode(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annotationLastScan:       report.Time.UTC().Format(time.RFC3339),
+				annotationOrphansFound:   strconv.Itoa(report.Found()),
+				annotationOrphansRemoved: strconv.Itoa(report.Removed()),
+			},
+		},
+	})
+
+	if err != nil {
+		log.Println("Cannot annotate node:", err.Error())
+	}
+
+}
+
+// conditionStatus is the status of the node condition last written, so the node is only patched on transitions.
+var conditionStatus string
+
+// setNodeCondition sets the configured node condition while at least min_orphans containers older than min_age are
+// left on the node, and clears it once the node is clean, so schedulers, autoscalers and dashboards can react.
+func setNodeCondition(report cycleReport) {
+
+	condition := config.NodeStatus.Condition
+
+	if condition.MinOrphans < 1 {
+		return
+	}
+
+	minAge := time.Duration(condition.MinAge) * time.Second
+	remaining := 0
+
+	for _, f := range report.Findings {
+		if f.Outcome != outcomeStopped && f.Age() >= minAge {
+			remaining++
+		}
+	}
+
+	status, reason := "False", "NoContainerGarbage"
+	message := "No dangling containers are left on the node."
+
+	if remaining >= condition.MinOrphans {
+		status, reason = "True", "DanglingContainersFound"
+		message = fmt.Sprintf("%d dangling containers older than %s are left on the node.", remaining, minAge)
+	}
+
+	if status == conditionStatus {
+		return
+	}
+
+	now := report.Time.UTC().Format(time.RFC3339)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []map[string]string{{
+				"type":               condition.Type,
+				"status":             status,
+				"reason":             reason,
+				"message":            message,
+				"lastHeartbeatTime":  now,
+				"lastTransitionTime": now,
+			}},
+		},
+	})
+
+	if err != nil {
+		log.Println("Cannot encode node condition:", err.Error())
+		return
+	}
+
+	// Conditions are merged by type, so a strategic merge patch leaves the kubelet's own conditions alone.
+	started := time.Now()
+	_, err = kubeClient.CoreV1().Nodes().PatchStatus(nodeFlag, data)
+	observeCall("kubernetes", "PatchNodeStatus", started, err)
+
+	if err != nil {
+		log.Println("Cannot set node condition:", err.Error())
+		return
+	}
+
+	conditionStatus = status
+
+}
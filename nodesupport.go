@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"k8s.io/api/core/v1"
+)
+
+// unsupportedNode returns why dcc cannot check the node, or an empty string if it can. Virtual-kubelet nodes have no
+// container runtime at all, and on nodes whose kubelet uses another runtime every Docker container would look
+// orphaned.
+func unsupportedNode(node *v1.Node) string {
+
+	if node.Labels["type"] == "virtual-kubelet" {
+		return "node is a virtual-kubelet node"
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == "virtual-kubelet.io/provider" {
+			return "node is a virtual-kubelet node of provider " + taint.Value
+		}
+	}
+
+	runtimeVersion := node.Status.NodeInfo.ContainerRuntimeVersion
+
+	if runtimeVersion == "" {
+		return "node reports no container runtime"
+	}
+
+	if runtime, _ := kube.ParseContainerID(runtimeVersion); runtime != kube.RuntimeDocker {
+		return "node runs the " + runtime + " container runtime"
+	}
+
+	return ""
+
+}
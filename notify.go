@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+)
+
+// finding is an orphan found in a cycle and the action taken on it.
+type finding struct {
+	ContainerID string            `json:"containerID"`
+	Image       string            `json:"image"`
+	ImageID     string            `json:"imageID"`
+	Created     time.Time         `json:"created"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	SizeBytes   int64             `json:"sizeBytes,omitempty"`
+	Rule        string            `json:"rule"`
+	Outcome     string            `json:"outcome"`
+	Reason      string            `json:"reason,omitempty"`
+	LogTail     string            `json:"logTail,omitempty"`
+	Snapshot    string            `json:"snapshot,omitempty"`
+	Escalated   bool              `json:"escalated,omitempty"`
+
+	Pod           string `json:"pod,omitempty"`
+	PodUID        string `json:"podUID,omitempty"`
+	PodState      string `json:"podState,omitempty"`
+	ContainerName string `json:"containerName,omitempty"`
+	Workload      string `json:"workload,omitempty"`
+}
+
+// ShortID returns the abbreviated container ID as shown by the Docker CLI.
+func (f finding) ShortID() string {
+	if len(f.ContainerID) > 12 {
+		return f.ContainerID[:12]
+	}
+	return f.ContainerID
+}
+
+// Age returns how long ago the container was created.
+func (f finding) Age() time.Duration {
+	return time.Since(f.Created).Round(time.Second)
+}
+
+// newFinding records the rule that classified an orphan container and the outcome of acting on it, with its former
+// pod and the tail of its logs when known.
+func newFinding(c types.Container, owner podOwner, rule, outcome, logTail string) finding {
+
+	f := finding{
+		ContainerID: c.ID,
+		Image:       c.Image,
+		ImageID:     c.ImageID,
+		Created:     time.Unix(c.Created, 0),
+		Labels:      c.Labels,
+		SizeBytes:   c.SizeRw,
+		Rule:        rule,
+		Outcome:     outcome,
+		LogTail:     logTail,
+	}
+
+	if owner.Name != "" {
+		f.Pod = owner.String()
+		f.PodUID = owner.UID
+		f.PodState = owner.State
+		f.ContainerName = owner.Container
+		f.Workload = owner.Workload
+	}
+
+	return f
+
+}
+
+// cycleReport is the digest of a single check cycle handed to every notifier.
+type cycleReport struct {
+	Cluster  string    `json:"cluster,omitempty"`
+	Node     string    `json:"node"`
+	Mode     string    `json:"mode"`
+	Time     time.Time `json:"time"`
+	Findings []finding `json:"findings"`
+	Failures []string  `json:"failures,omitempty"`
+}
+
+// Found returns the number of orphans found in the cycle.
+func (r cycleReport) Found() int {
+	return len(r.Findings)
+}
+
+// Removed returns the number of orphans stopped in the cycle.
+func (r cycleReport) Removed() int {
+	removed := 0
+	for _, f := range r.Findings {
+		if f.Outcome == outcomeStopped {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Escalated returns true if the stops of any container in the cycle failed escalate_after times.
+func (r cycleReport) Escalated() bool {
+	for _, f := range r.Findings {
+		if f.Escalated {
+			return true
+		}
+	}
+	return false
+}
+
+// imageCount is the number of orphans of a single image in a cycle.
+type imageCount struct {
+	Image string `json:"image"`
+	Count int    `json:"count"`
+}
+
+// maxTopImages bounds the number of images listed in a digest.
+const maxTopImages = 5
+
+// TopImages returns the images with the most orphans in the cycle, most frequent first.
+func (r cycleReport) TopImages() []imageCount {
+
+	counts := map[string]int{}
+	for _, f := range r.Findings {
+		counts[f.Image]++
+	}
+
+	var top []imageCount
+	for image, count := range counts {
+		top = append(top, imageCount{Image: image, Count: count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Image < top[j].Image
+	})
+
+	if len(top) > maxTopImages {
+		top = top[:maxTopImages]
+	}
+
+	return top
+
+}
+
+// TotalAge returns the combined age of all orphans in the cycle.
+func (r cycleReport) TotalAge() time.Duration {
+	var total time.Duration
+	for _, f := range r.Findings {
+		total += f.Age()
+	}
+	return total
+}
+
+// TotalSizeBytes returns the combined writable layer size of all orphans in the cycle. Sizes are only known when
+// collect_sizes is enabled.
+func (r cycleReport) TotalSizeBytes() int64 {
+	var total int64
+	for _, f := range r.Findings {
+		total += f.SizeBytes
+	}
+	return total
+}
+
+// notifier delivers cycle digests to an external system.
+type notifier interface {
+	Name() string
+	Notify(report cycleReport) error
+}
+
+// resolver is implemented by sinks that keep alerts open while a condition lasts. They are also shown the reports
+// of cycles too quiet to notify about, so they can close alerts once the condition clears.
+type resolver interface {
+	Resolve(report cycleReport) error
+}
+
+// flusher is implemented by sinks that batch reports, so the batch can be sent before dcc exits.
+type flusher interface {
+	Flush() error
+}
+
+// sink is a notifier with an identity that is unique across routes, used to keep its dead letters apart, and the
+// queue of reports waiting for it.
+type sink struct {
+	notifier
+	id    string
+	queue *sinkQueue
+}
+
+// newSink starts delivering the notifier's reports in the background.
+func newSink(owner string, n notifier) sink {
+
+	s := sink{notifier: n, id: owner + "-" + n.Name(), queue: newSinkQueue()}
+	go s.run()
+
+	return s
+
+}
+
+// route sends the findings matching its rule to its own sinks.
+type route struct {
+	name      string
+	match     dccconfig.RouteMatch
+	cont      bool
+	notifiers []sink
+}
+
+var (
+	notifiers  []sink
+	routes     []route
+	httpClient = &http.Client{Timeout: 15 * time.Second}
+)
+
+// setupNotifiers creates the default sinks and the sinks of every routing rule.
+func setupNotifiers() {
+
+	notifiers = buildNotifiers("default", config.Notifications.Sinks)
+
+	for i, r := range config.Notifications.Routes {
+
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("route-%d", i)
+		}
+
+		routes = append(routes, route{
+			name:      name,
+			match:     r.Match,
+			cont:      r.Continue,
+			notifiers: buildNotifiers(name, r.Sinks),
+		})
+
+	}
+
+}
+
+// buildNotifiers creates a notifier for every configured sink. Sinks that cannot be set up are logged and skipped.
+func buildNotifiers(owner string, sinks dccconfig.Sinks) []sink {
+
+	var built []sink
+
+	if sinks.Slack.WebhookURLFile != "" {
+		if n, err := newSlackNotifier(sinks.Slack); err != nil {
+			log.Println("Slack notifications disabled for", owner+":", err.Error())
+		} else {
+			built = append(built, newSink(owner, n))
+		}
+	}
+
+	if sinks.Webhook.URL != "" {
+		if n, err := newWebhookNotifier(sinks.Webhook); err != nil {
+			log.Println("Webhook notifications disabled for", owner+":", err.Error())
+		} else {
+			built = append(built, newSink(owner, n))
+		}
+	}
+
+	if sinks.Email.SMTPHost != "" {
+		if n, err := newEmailNotifier(sinks.Email); err != nil {
+			log.Println("Email notifications disabled for", owner+":", err.Error())
+		} else {
+			built = append(built, newSink(owner, n))
+		}
+	}
+
+	if sinks.Opsgenie.APIKeyFile != "" {
+		if n, err := newOpsgenieNotifier(sinks.Opsgenie, owner); err != nil {
+			log.Println("Opsgenie notifications disabled for", owner+":", err.Error())
+		} else {
+			built = append(built, newSink(owner, n))
+		}
+	}
+
+	return built
+
+}
+
+// allSinks returns the default sinks and the sinks of every route.
+func allSinks() []sink {
+
+	sinks := append([]sink(nil), notifiers...)
+	for _, r := range routes {
+		sinks = append(sinks, r.notifiers...)
+	}
+
+	return sinks
+
+}
+
+// flushNotifiers sends the batches held by every sink.
+func flushNotifiers() {
+
+	for _, s := range allSinks() {
+		if f, ok := s.notifier.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				log.Println("Flushing", s.id, "failed:", err.Error())
+			}
+		}
+	}
+
+}
+
+// routeMatches returns true if the finding satisfies every condition of the rule. An empty rule matches everything.
+func routeMatches(m dccconfig.RouteMatch, f finding) bool {
+
+	if m.Namespace != "" && f.Labels[labelPodNamespace] != m.Namespace {
+		return false
+	}
+
+	if m.Image != "" && !strings.Contains(f.Image, m.Image) {
+		return false
+	}
+
+	for key, value := range m.Labels {
+		if f.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// notify routes the findings of a cycle and hands each set of sinks a single digest of the findings routed to it.
+// Findings go to the first matching route, or to every matching route up to one without continue, and to the
+// default sinks when no route claims them.
+func notify(report cycleReport) {
+
+	routed := make([][]finding, len(routes))
+	var unrouted []finding
+
+	for _, f := range report.Findings {
+
+		claimed := false
+
+		for i, r := range routes {
+
+			if !routeMatches(r.match, f) {
+				continue
+			}
+
+			routed[i] = append(routed[i], f)
+			claimed = true
+
+			if !r.cont {
+				break
+			}
+
+		}
+
+		if !claimed {
+			unrouted = append(unrouted, f)
+		}
+
+	}
+
+	// Cycle failures concern the platform rather than any workload, so only the default sinks receive them.
+	for i, r := range routes {
+		routedReport := withFindings(report, routed[i])
+		routedReport.Failures = nil
+		deliver(r.notifiers, routedReport)
+	}
+
+	deliver(notifiers, withFindings(report, unrouted))
+
+}
+
+// withFindings returns a copy of the report restricted to the given findings.
+func withFindings(report cycleReport, findings []finding) cycleReport {
+	report.Findings = findings
+	return report
+}
+
+// deliver queues the report for the given sinks. Reports with fewer findings than min_findings are not sent unless
+// the cycle failed or a container's stop failures were escalated; they only replay dead letters and resolve alerts.
+func deliver(sinks []sink, report cycleReport) {
+
+	threshold := config.Notifications.MinFindings
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	quiet := report.Found() < threshold && len(report.Failures) == 0 && !report.Escalated()
+
+	for _, s := range sinks {
+		s.enqueue(delivery{report: report, quiet: quiet})
+	}
+
+}
+
+// readSecret returns the trimmed contents of a file, typically a key of a mounted Secret.
+func readSecret(path string) (string, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+
+}
+
+// renderTemplate renders a notification body from a Go template.
+func renderTemplate(tmpl *template.Template, report cycleReport) (string, error) {
+
+	var body bytes.Buffer
+
+	if err := tmpl.Execute(&body, report); err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const emailTemplate = `Dangling container summary for node {{.Node}} ({{.Mode}} mode)
+Period: {{.Since.Format "2006-01-02 15:04 MST"}} to {{.Until.Format "2006-01-02 15:04 MST"}}
+
+Cycles reported:      {{.Cycles}}
+Orphans found:        {{.Found}}
+Orphans removed:      {{.Removed}}
+Cycle failures:       {{len .Failures}}
+{{if .Failures}}
+Failures:
+{{range .Failures}}  {{.}}
+{{end}}{{end}}{{if .Findings}}
+Containers:
+{{range .Findings}}  {{.ShortID}}  {{.Image}}  {{.Outcome}}
+{{end}}{{end}}`
+
+// maxEmailFailures bounds the cycle failures listed in a single summary.
+const maxEmailFailures = 20
+
+// emailSummary accumulates the findings of a reporting period.
+type emailSummary struct {
+	Node     string
+	Mode     string
+	Since    time.Time
+	Until    time.Time
+	Cycles   int
+	Found    int
+	Removed  int
+	Findings []finding
+	Failures []string
+}
+
+// emailNotifier collects cycle reports and mails a summary of them every interval.
+type emailNotifier struct {
+	cfg      dccconfig.Email
+	password string
+	template *template.Template
+
+	mutex   sync.Mutex
+	summary emailSummary
+	seen    map[string]bool
+}
+
+// newEmailNotifier reads the SMTP password from its Secret file and starts the periodic summary.
+func newEmailNotifier(cfg dccconfig.Email) (*emailNotifier, error) {
+
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("no recipients configured")
+	}
+
+	e := &emailNotifier{cfg: cfg, template: template.Must(template.New("email").Parse(emailTemplate))}
+
+	if cfg.PasswordFile != "" {
+		password, err := readSecret(cfg.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		e.password = password
+	}
+
+	e.reset(time.Now())
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if err := e.send(); err != nil {
+				log.Println("Email summary failed:", err.Error())
+			}
+		}
+	}()
+
+	return e, nil
+
+}
+
+func (e *emailNotifier) Name() string {
+	return "email"
+}
+
+// Notify adds the report to the current summary; nothing is sent until the period ends.
+func (e *emailNotifier) Notify(report cycleReport) error {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.summary.Cycles++
+	e.summary.Found += report.Found()
+	e.summary.Removed += report.Removed()
+
+	for _, failure := range report.Failures {
+		if len(e.summary.Failures) < maxEmailFailures {
+			e.summary.Failures = append(e.summary.Failures, report.Time.Format(time.RFC3339)+" "+failure)
+		}
+	}
+
+	// List each container once per period, with the outcome of its first sighting.
+	for _, f := range report.Findings {
+		if !e.seen[f.ContainerID] {
+			e.seen[f.ContainerID] = true
+			e.summary.Findings = append(e.summary.Findings, f)
+		}
+	}
+
+	return nil
+
+}
+
+// Flush mails the summary of the period so far.
+func (e *emailNotifier) Flush() error {
+	return e.send()
+}
+
+func (e *emailNotifier) reset(now time.Time) {
+	e.summary = emailSummary{Node: nodeFlag, Mode: modeFlag, Since: now}
+	e.seen = map[string]bool{}
+}
+
+// send mails the summary of the period that just ended and starts a new one. Quiet periods are not mailed.
+func (e *emailNotifier) send() error {
+
+	e.mutex.Lock()
+	summary := e.summary
+	summary.Until = time.Now()
+	e.reset(summary.Until)
+	e.mutex.Unlock()
+
+	if summary.Found == 0 && len(summary.Failures) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+
+	if err := e.template.Execute(&body, summary); err != nil {
+		return err
+	}
+
+	subject := e.cfg.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("[dcc] %s: %d dangling container(s) found, %d removed", summary.Node, summary.Found, summary.Removed)
+	}
+
+	message := "From: " + e.cfg.From + "\r\n" +
+		"To: " + strings.Join(e.cfg.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body.String()
+
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.password, e.cfg.SMTPHost)
+	}
+
+	address := net.JoinHostPort(e.cfg.SMTPHost, strconv.Itoa(e.cfg.SMTPPort))
+
+	return smtp.SendMail(address, auth, e.cfg.From, e.cfg.To, []byte(message))
+
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const defaultOpsgenieURL = "https://api.opsgenie.com"
+
+// Conditions raised as Opsgenie alerts. Each condition has one alert per node, identified by its alias, so repeated
+// cycles update the same alert instead of opening new ones.
+const (
+	conditionOrphans      = "orphans"
+	conditionCycleFailure = "cycle-failure"
+)
+
+var opsgenieConditions = []string{conditionOrphans, conditionCycleFailure}
+
+// opsgenieNotifier opens an alert per node and condition and closes it when the condition clears.
+type opsgenieNotifier struct {
+	apiKey   string
+	apiURL   string
+	priority string
+	tags     []string
+	owner    string
+
+	mutex sync.Mutex
+	// open tracks conditions that may have an open alert. Everything starts open so alerts left by a previous run
+	// are closed once the node is found clean.
+	open map[string]bool
+}
+
+// newOpsgenieNotifier reads the API key from its Secret file.
+func newOpsgenieNotifier(cfg dccconfig.Opsgenie, owner string) (*opsgenieNotifier, error) {
+
+	key, err := readSecret(cfg.APIKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	o := &opsgenieNotifier{
+		apiKey:   key,
+		apiURL:   strings.TrimRight(cfg.APIURL, "/"),
+		priority: cfg.Priority,
+		tags:     cfg.Tags,
+		owner:    owner,
+		open:     map[string]bool{},
+	}
+
+	if o.apiURL == "" {
+		o.apiURL = defaultOpsgenieURL
+	}
+
+	for _, condition := range opsgenieConditions {
+		o.open[condition] = true
+	}
+
+	return o, nil
+
+}
+
+func (o *opsgenieNotifier) Name() string {
+	return "opsgenie"
+}
+
+// alias identifies the alert of a condition on this node.
+func (o *opsgenieNotifier) alias(condition string) string {
+	return fmt.Sprintf("dcc-%s-%s-%s", nodeFlag, o.owner, condition)
+}
+
+// Notify opens or updates alerts for the conditions present in the report and closes the others.
+func (o *opsgenieNotifier) Notify(report cycleReport) error {
+
+	active := map[string]string{}
+
+	if report.Found() > 0 {
+		active[conditionOrphans] = fmt.Sprintf("dcc: %d dangling container(s) on %s", report.Found(), report.Node)
+	}
+
+	if len(report.Failures) > 0 {
+		active[conditionCycleFailure] = fmt.Sprintf("dcc: check cycle failing on %s", report.Node)
+	}
+
+	for _, condition := range opsgenieConditions {
+
+		var err error
+
+		if message, ok := active[condition]; ok {
+			err = o.create(condition, message, report)
+		} else {
+			err = o.close(condition)
+		}
+
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// Resolve closes every alert that may be open, since the cycle found nothing worth reporting.
+func (o *opsgenieNotifier) Resolve(report cycleReport) error {
+
+	for _, condition := range opsgenieConditions {
+		if err := o.close(condition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+func (o *opsgenieNotifier) create(condition, message string, report cycleReport) error {
+
+	details := map[string]string{
+		"node":    report.Node,
+		"mode":    report.Mode,
+		"found":   fmt.Sprint(report.Found()),
+		"removed": fmt.Sprint(report.Removed()),
+	}
+
+	var description strings.Builder
+
+	for _, failure := range report.Failures {
+		description.WriteString("Cycle failure: " + failure + "\n")
+	}
+
+	for _, image := range report.TopImages() {
+		description.WriteString(fmt.Sprintf("%s ×%d\n", image.Image, image.Count))
+	}
+
+	err := o.post("/v2/alerts", map[string]interface{}{
+		"message":     message,
+		"alias":       o.alias(condition),
+		"description": description.String(),
+		"priority":    o.priority,
+		"tags":        o.tags,
+		"details":     details,
+		"entity":      report.Node,
+		"source":      "dcc",
+	})
+
+	if err == nil {
+		o.mutex.Lock()
+		o.open[condition] = true
+		o.mutex.Unlock()
+	}
+
+	return err
+
+}
+
+func (o *opsgenieNotifier) close(condition string) error {
+
+	o.mutex.Lock()
+	open := o.open[condition]
+	o.mutex.Unlock()
+
+	if !open {
+		return nil
+	}
+
+	err := o.post("/v2/alerts/"+url.PathEscape(o.alias(condition))+"/close?identifierType=alias", map[string]string{
+		"source": "dcc",
+		"note":   "Condition cleared on " + nodeFlag,
+	})
+
+	if err == nil {
+		o.mutex.Lock()
+		o.open[condition] = false
+		o.mutex.Unlock()
+	}
+
+	return err
+
+}
+
+func (o *opsgenieNotifier) post(path string, payload interface{}) error {
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.apiURL+path, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.apiKey)
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	// Closing an alert that does not exist is not an error worth retrying.
+	if resp.StatusCode == http.StatusNotFound && strings.HasSuffix(path, "identifierType=alias") {
+		return nil
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("opsgenie returned %s", resp.Status)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"text/template"
+)
+
+const defaultSlackTemplate = `{{range .Failures}}:warning: *dcc on {{$.Node}}* cycle failed: {{.}}
+{{end}}*dcc on {{if .Cluster}}{{.Cluster}}/{{end}}{{.Node}}* ({{.Mode}} mode): {{.Found}} dangling container(s) found, {{.Removed}} removed.
+Total age {{.TotalAge}}{{if .TotalSizeBytes}}, {{.TotalSizeBytes}} bytes{{end}}. Top images:
+{{range .TopImages}}• {{.Image}} ×{{.Count}}
+{{end}}{{range .Findings}}{{if .Pod}}• ` + "`{{.ShortID}}`" + ` was {{.ContainerName}} in pod {{.Pod}} ({{.PodState}}){{if .Workload}} of {{.Workload}}{{end}}
+{{end}}{{end}}{{range .Findings}}{{if .LogTail}}Last logs of ` + "`{{.ShortID}}`" + ` ({{.Image}}):
+` + "```\n{{.LogTail}}\n```" + `
+{{end}}{{end}}`
+
+// slackNotifier posts cycle digests to a Slack incoming webhook.
+type slackNotifier struct {
+	webhookURL string
+	channel    string
+	template   *template.Template
+}
+
+// newSlackNotifier reads the webhook URL from its Secret file and parses the message template.
+func newSlackNotifier(cfg dccconfig.Slack) (*slackNotifier, error) {
+
+	url, err := readSecret(cfg.WebhookURLFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	text := cfg.Template
+	if text == "" {
+		text = defaultSlackTemplate
+	}
+
+	tmpl, err := template.New("slack").Parse(text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &slackNotifier{webhookURL: url, channel: cfg.Channel, template: tmpl}, nil
+
+}
+
+func (s *slackNotifier) Name() string {
+	return "slack"
+}
+
+func (s *slackNotifier) Notify(report cycleReport) error {
+
+	text, err := renderTemplate(s.template, report)
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"channel":  s.channel,
+		"username": "dcc",
+		"text":     text,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(s.webhookURL, "application/json", bytes.NewReader(payload))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+
+	return nil
+
+}
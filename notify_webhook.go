@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"net/http"
+)
+
+// signatureHeader carries the hex encoded HMAC-SHA256 of the request body when a signing secret is configured.
+const signatureHeader = "X-DCC-Signature"
+
+// webhookNotifier POSTs cycle digests as JSON to an arbitrary HTTP endpoint.
+type webhookNotifier struct {
+	url     string
+	secret  []byte
+	headers map[string]string
+}
+
+// webhookPayload is the JSON document sent to the webhook.
+type webhookPayload struct {
+	cycleReport
+	Found          int          `json:"found"`
+	Removed        int          `json:"removed"`
+	TopImages      []imageCount `json:"topImages"`
+	TotalAgeSecs   int64        `json:"totalAgeSeconds"`
+	TotalSizeBytes int64        `json:"totalSizeBytes,omitempty"`
+}
+
+// newWebhookNotifier reads the optional signing secret from its Secret file.
+func newWebhookNotifier(cfg dccconfig.Webhook) (*webhookNotifier, error) {
+
+	n := &webhookNotifier{url: cfg.URL, headers: cfg.Headers}
+
+	if cfg.SecretFile != "" {
+		secret, err := readSecret(cfg.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		n.secret = []byte(secret)
+	}
+
+	return n, nil
+
+}
+
+func (w *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify sends the report.
+func (w *webhookNotifier) Notify(report cycleReport) error {
+
+	body, err := json.Marshal(webhookPayload{
+		cycleReport:    report,
+		Found:          report.Found(),
+		Removed:        report.Removed(),
+		TopImages:      report.TopImages(),
+		TotalAgeSecs:   int64(report.TotalAge().Seconds()),
+		TotalSizeBytes: report.TotalSizeBytes(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.post(body)
+
+}
+
+// sign returns the hex encoded HMAC-SHA256 of body.
+func (w *webhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookNotifier) post(body []byte) error {
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	if len(w.secret) > 0 {
+		req.Header.Set(signatureHeader, w.sign(body))
+	}
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+
+}
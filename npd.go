@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modeNPD runs a single check as a node-problem-detector custom plugin.
+const modeNPD = "npd"
+
+// Exit codes of a node-problem-detector custom plugin.
+const (
+	npdOK      = 0
+	npdNonOK   = 1
+	npdUnknown = 2
+)
+
+// runNPDPlugin runs a single check without acting on its findings, prints a one-line status for node-problem-detector
+// on stdout and returns the plugin exit code. Events and conditions are left to node-problem-detector.
+func runNPDPlugin() int {
+
+	var failures cycleFailures
+
+	ctx, cancel := cycleContext()
+	defer cancel()
+
+	_, result, err := checkContainers(ctx, nil, &failures)
+
+	if err != nil {
+		printNPDStatus("Check failed: " + strings.Join(failures.list(), "; "))
+		return npdUnknown
+	}
+
+	orphans := excludeStaticPods(result.Orphans, result.Checked, &failures)
+
+	if len(orphans) < config.NPD.MinOrphans || len(orphans) == 0 {
+		printNPDStatus("No dangling containers found.")
+		return npdOK
+	}
+
+	var ids []string
+	for _, c := range orphans {
+		ids = append(ids, fmt.Sprintf("%.12s", c.ID))
+	}
+
+	printNPDStatus(fmt.Sprintf("%d dangling containers: %s", len(orphans), strings.Join(ids, " ")))
+	return npdNonOK
+
+}
+
+// printNPDStatus prints the plugin status, cut to the output length node-problem-detector accepts.
+func printNPDStatus(message string) {
+
+	if max := config.NPD.MaxOutputLength; max > 0 && len(message) > max {
+		message = message[:max]
+	}
+
+	fmt.Println(message)
+
+}
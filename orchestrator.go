@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"k8s.io/api/core/v1"
+)
+
+// orchestrator is what decides which containers should run on the node. The cycle only learns the containers it
+// knows, and reports what it found, through it, so orchestrators other than Kubernetes, or a fake, can stand in.
+type orchestrator interface {
+	// KnownContainerIDs returns the runtime IDs of the containers that should exist on the node. A failure to learn
+	// all of them, which aborts the cycle, is returned as a stageError.
+	KnownContainerIDs() ([]string, error)
+
+	// NodeRef refers to the node in events.
+	NodeRef() *v1.ObjectReference
+
+	// Event records an event about the node or one of its containers.
+	Event(event dccEvent)
+}
+
+// kubernetesOrchestrator is the Kubernetes cluster the node belongs to.
+type kubernetesOrchestrator struct{}
+
+// nodeOrchestrator is the orchestrator of the node.
+var nodeOrchestrator orchestrator = kubernetesOrchestrator{}
+
+// KnownContainerIDs connects to the Kubernetes API to retrieve a list of containers running in all pods running on
+// the same node as the Docker daemon. The pods come from the informer cache once it has synced; otherwise they are
+// listed with the apiserver filtering by node, in pages of page_size pods.
+func (kubernetesOrchestrator) KnownContainerIDs() ([]string, error) {
+
+	if podCacheReady() {
+
+		containerIDs, err := cachedPodContainers()
+
+		if err != nil {
+			log.Println("Error in reading the pod cache:", err.Error())
+			return nil, stageError{stage: "pod-cache", err: err}
+		}
+
+		return containerIDs, nil
+
+	}
+
+	pods := &kube.NodePods{Client: kubeClient, Node: nodeFlag, PageSize: config.Kubernetes.PageSize, Call: kubernetesCall}
+	containerIDs, err := pods.KnownContainerIDs()
+
+	if err != nil {
+		log.Println("Error in listing pods:", err.Error())
+		return nil, stageError{stage: "pod-list", err: err}
+	}
+
+	return containerIDs, nil
+
+}
+
+func (kubernetesOrchestrator) NodeRef() *v1.ObjectReference {
+	return nodeEventReference()
+}
+
+func (kubernetesOrchestrator) Event(event dccEvent) {
+	dispatcher.dispatch(event)
+}
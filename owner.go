@@ -0,0 +1,144 @@
+package main
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// States of the pod an orphan belonged to.
+const (
+	podStateExists      = "exists"
+	podStateTerminating = "terminating"
+	podStateReplaced    = "replaced"
+	podStateDeleted     = "deleted"
+	podStateUnknown     = "unknown"
+)
+
+// podOwner identifies the pod a container was created for, as recorded in the kubelet's container labels.
+type podOwner struct {
+	Name      string
+	Namespace string
+	UID       string
+	Container string
+	State     string
+	Workload  string
+
+	// TeardownDeadline is when a terminating pod's grace period ends.
+	TeardownDeadline time.Time
+
+	// GracePeriod is the pod's termination grace period, when known.
+	GracePeriod *time.Duration
+}
+
+// String returns the owner as namespace/name, or an empty string when the container has no pod labels.
+func (o podOwner) String() string {
+	if o.Name == "" {
+		return ""
+	}
+	return o.Namespace + "/" + o.Name
+}
+
+// inferPodOwner reads the pod identity from the container's labels and looks up whether that pod still exists. A
+// pod with the same name but another UID means the pod was replaced, e.g. by a StatefulSet. The workload of a pod
+// that is gone comes from the owner references the pod informer remembered when it saw the pod deleted.
+func inferPodOwner(c types.Container) podOwner {
+
+	owner := podOwner{
+		Name:      c.Labels[labelPodName],
+		Namespace: c.Labels[labelPodNamespace],
+		UID:       c.Labels[labelPodUID],
+		Container: c.Labels[labelContainerName],
+		State:     podStateUnknown,
+	}
+
+	if owner.Name == "" || owner.Namespace == "" {
+		return owner
+	}
+
+	var pod *v1.Pod
+	err := retryAPI("GetPod", func() (err error) {
+		started := time.Now()
+		pod, err = kubeClient.CoreV1().Pods(owner.Namespace).Get(owner.Name, metav1.GetOptions{})
+		observeCall("kubernetes", "GetPod", started, err)
+		return err
+	})
+
+	switch {
+	case errors.IsNotFound(err):
+		owner.State = podStateDeleted
+		if deleted, ok := rememberedDeletedPod(owner.UID); ok {
+			owner.GracePeriod = deleted.grace
+			owner.Workload = topLevelWorkload(owner.Namespace, deleted.owners)
+		}
+	case err != nil:
+		owner.State = podStateUnknown
+	case owner.UID != "" && string(pod.UID) != owner.UID:
+		owner.State = podStateReplaced
+	case pod.DeletionTimestamp != nil:
+		// The apiserver sets the deletion timestamp to the end of the grace period, not to when deletion was asked.
+		owner.State = podStateTerminating
+		owner.TeardownDeadline = pod.DeletionTimestamp.Time
+		if pod.DeletionGracePeriodSeconds != nil {
+			grace := time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second
+			owner.GracePeriod = &grace
+		}
+	default:
+		owner.State = podStateExists
+	}
+
+	if err == nil && owner.GracePeriod == nil && owner.State != podStateReplaced && pod.Spec.TerminationGracePeriodSeconds != nil {
+		grace := time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+		owner.GracePeriod = &grace
+	}
+
+	// A replacing pod with the same name is almost always created by the same workload.
+	if err == nil {
+		owner.Workload = topLevelWorkload(owner.Namespace, pod.OwnerReferences)
+	}
+
+	return owner
+
+}
+
+// stopTimeout returns how long Docker waits for the container to exit before killing it: the pod's own grace period
+// when known, as the kubelet would have used, capped at max_stop_timeout, and stop_timeout otherwise.
+func (o podOwner) stopTimeout() time.Duration {
+
+	timeout := time.Duration(config.Timing.StopTimeout) * time.Second
+
+	if o.GracePeriod != nil {
+		timeout = *o.GracePeriod
+	}
+
+	if max := time.Duration(config.Timing.MaxStopTimeout) * time.Second; max > 0 && timeout > max {
+		timeout = max
+	}
+
+	return timeout
+
+}
+
+// podUIDGuard only lets containers labelled with a pod UID be stopped once the GET in inferPodOwner has confirmed
+// that pod gone or replaced by one with another UID. A pod that still exists, or could not be fetched, means the
+// container may well be in use and merely missing from a stale status. Pods stuck terminating past their deadline
+// were already left alone until then.
+func podUIDGuard(c types.Container, owner podOwner) string {
+
+	if owner.UID == "" {
+		return ""
+	}
+
+	switch owner.State {
+	case podStateDeleted, podStateReplaced, podStateTerminating:
+		return ""
+	case podStateExists:
+		return "pod " + owner.String() + " still exists with the container's UID"
+	default:
+		return "pod " + owner.String() + " could not be confirmed gone"
+	}
+
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInferPodOwnerTerminating(t *testing.T) {
+
+	savedClient := kubeClient
+	defer func() { kubeClient = savedClient }()
+
+	// Deleted with a 30 second grace period: the apiserver sets the deletion timestamp to when it ends.
+	deadline := metav1.NewTime(time.Now().Add(30 * time.Second).Truncate(time.Second))
+	grace := int64(30)
+
+	kubeClient = fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:                       "web-1",
+			Namespace:                  "default",
+			UID:                        "uid-1",
+			DeletionTimestamp:          &deadline,
+			DeletionGracePeriodSeconds: &grace,
+		},
+	})
+
+	c := testContainer(testID("a1"))
+	c.Labels = map[string]string{labelPodNamespace: "default", labelPodName: "web-1", labelPodUID: "uid-1"}
+
+	owner := inferPodOwner(c)
+
+	if owner.State != podStateTerminating {
+		t.Fatalf("state = %s, want %s", owner.State, podStateTerminating)
+	}
+
+	if !owner.TeardownDeadline.Equal(deadline.Time) {
+		t.Errorf("teardown deadline = %s, want the deletion timestamp %s", owner.TeardownDeadline, deadline.Time)
+	}
+
+	if owner.GracePeriod == nil || *owner.GracePeriod != 30*time.Second {
+		t.Errorf("grace period = %v, want 30s", owner.GracePeriod)
+	}
+
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// permission is an API access dcc needs, with the feature that needs it.
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	namespace   string
+	feature     string
+	optional    bool
+}
+
+// String names the permission the way RBAC rules spell it.
+func (p permission) String() string {
+
+	resource := p.resource
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+
+	if p.group != "" {
+		resource += "." + p.group
+	}
+
+	if p.namespace != "" {
+		resource += " in namespace " + p.namespace
+	}
+
+	return p.verb + " " + resource
+
+}
+
+// requiredPermissions returns the permissions the configuration calls for.
+func requiredPermissions() []permission {
+
+	permissions := []permission{
+		{verb: "get", resource: "nodes", feature: "node lookup"},
+		{verb: "list", resource: "nodes", feature: "node watch"},
+		{verb: "watch", resource: "nodes", feature: "node watch"},
+		{verb: "list", resource: "pods", feature: "pod list"},
+		{verb: "get", resource: "pods", feature: "pod owner lookup"},
+		{verb: "get", group: "apps", resource: "replicasets", feature: "workload attribution", optional: true},
+		{verb: "get", group: "batch", resource: "jobs", feature: "workload attribution", optional: true},
+		{verb: "get", group: "coordination.k8s.io", resource: "leases", namespace: nodeLeaseNamespace, feature: "kubelet health", optional: true},
+	}
+
+	add := func(feature, verb, group, resource, subresource, namespace string) {
+		permissions = append(permissions, permission{verb: verb, group: group, resource: resource, subresource: subresource, namespace: namespace, feature: feature})
+	}
+
+	events := ""
+	if eventsV1 {
+		events = "events.k8s.io"
+	}
+
+	add("events", "create", events, "events", "", "")
+	add("events", "patch", events, "events", "", "")
+
+	if config.Kubernetes.UseInformer {
+		add("pod informer", "watch", "", "pods", "", "")
+	}
+
+	if config.NodeStatus.Annotations {
+		add("node annotations", "patch", "", "nodes", "", "")
+	}
+
+	if config.NodeStatus.Condition.MinOrphans > 0 {
+		add("node condition", "patch", "", "nodes", "status", "")
+	}
+
+	if config.NodeStatus.Taint.MaxOrphans > 0 || config.NodeStatus.Taint.MaxSizeMB > 0 {
+		add("node taint", "update", "", "nodes", "", "")
+	}
+
+	if config.Kubelet.CrossCheck {
+		add("kubelet cross-check", "get", "", "nodes", "proxy", "")
+	}
+
+	if config.NamespaceDeletion.Window > 0 {
+		add("namespace deletions", "list", "", "namespaces", "", "")
+		add("namespace deletions", "watch", "", "namespaces", "", "")
+	}
+
+	if ns := config.Summary.ConfigMapNamespace; ns != "" {
+		for _, verb := range []string{"get", "create", "update"} {
+			add("summary", verb, "", "configmaps", "", ns)
+		}
+	}
+
+	if ns := config.Reports.CRD.Namespace; ns != "" {
+		for _, verb := range []string{"get", "create", "update"} {
+			add("report resources", verb, crdGroup, "danglingcontainerreports", "", ns)
+		}
+	}
+
+	if ns := config.DanglingContainers.Namespace; ns != "" {
+		for _, verb := range []string{"get", "create", "update"} {
+			add("DanglingContainer resources", verb, crdGroup, "danglingcontainers", "", ns)
+		}
+	}
+
+	if ns := config.Heartbeat.Namespace; ns != "" {
+		for _, verb := range []string{"get", "create", "update"} {
+			add("heartbeat", verb, "coordination.k8s.io", "leases", "", ns)
+		}
+	}
+
+	if config.LeaderElection.Enabled {
+		for _, verb := range []string{"get", "create", "update"} {
+			add("leader election", verb, "", "configmaps", "", config.LeaderElection.Namespace)
+		}
+	}
+
+	return permissions
+
+}
+
+// checkPermissions asks the apiserver whether dcc may do everything its configuration calls for, and returns an error
+// naming every missing permission instead of failing later with an opaque 403. A required permission whose review
+// fails is unverified and fails the check as well, after the others were checked. Optional permissions only log a
+// warning.
+func checkPermissions() error {
+
+	var missing, unverified []string
+
+	for _, p := range requiredPermissions() {
+
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        p.verb,
+					Group:       p.group,
+					Resource:    p.resource,
+					Subresource: p.subresource,
+					Namespace:   p.namespace,
+				},
+			},
+		}
+
+		result, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+
+		if err != nil {
+			log.Println("Cannot check permission to", p.String()+":", err.Error())
+			if !p.optional {
+				unverified = append(unverified, p.String()+" ("+err.Error()+")")
+			}
+			continue
+		}
+
+		if result.Status.Allowed {
+			continue
+		}
+
+		if p.optional {
+			log.Println("Missing optional permission:", p.String(), "("+p.feature+" disabled)")
+			continue
+		}
+
+		missing = append(missing, p.String()+" (needed for "+p.feature+")")
+
+	}
+
+	switch {
+	case len(missing) > 0 && len(unverified) > 0:
+		return fmt.Errorf("missing permissions: %s; unverified permissions: %s", strings.Join(missing, ", "), strings.Join(unverified, ", "))
+	case len(missing) > 0:
+		return fmt.Errorf("missing permissions: %s", strings.Join(missing, ", "))
+	case len(unverified) > 0:
+		return fmt.Errorf("unverified permissions: %s", strings.Join(unverified, ", "))
+	}
+
+	return nil
+
+}
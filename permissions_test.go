@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissionsFailsUnverified(t *testing.T) {
+
+	savedClient := kubeClient
+	defer func() { kubeClient = savedClient }()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("apiserver unavailable")
+	})
+	kubeClient = client
+
+	err := checkPermissions()
+
+	if err == nil || !strings.Contains(err.Error(), "unverified permissions") {
+		t.Errorf("checkPermissions() = %v, want the unverified permissions", err)
+	}
+
+}
@@ -0,0 +1,196 @@
+// Package checker finds orphaned containers: containers running on a node that none of the node's pods accounts for,
+// typically left behind by a kubelet that failed to tear a pod down. It only finds them; what to do about them is up
+// to the caller. A node agent embeds it as
+//
+//	result, err := checker.Run(ctx, checker.Options{
+//		Runtime: &runtime.Docker{Client: cli},
+//		Pods:    &kube.NodePods{Client: client, Node: node},
+//		MinAge:  3 * time.Minute,
+//	})
+package checker
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+)
+
+// labelPodNamespace is how the kubelet's Docker integration records the namespace of a container's pod.
+const labelPodNamespace = "io.kubernetes.pod.namespace"
+
+// PodSource tells which containers the pods of the node account for. kube.NodePods lists them from the apiserver.
+type PodSource interface {
+	// KnownContainerIDs returns the runtime IDs of the containers of all pods on the node.
+	KnownContainerIDs() ([]string, error)
+}
+
+// Options configure a check.
+type Options struct {
+	// Runtime lists the containers on the node.
+	Runtime runtime.Runtime
+
+	// Pods tells which of them the node's pods account for.
+	Pods PodSource
+
+	// Exclude, when set, returns true for containers that are never orphans, such as those of whitelisted images.
+	Exclude func(types.Container) bool
+
+	// Namespaces, when set, limit the check to the containers of pods in these namespaces.
+	Namespaces []string
+
+	// MinAge is how old a container must be to be an orphan. The runtime creates a container before the pod's status
+	// lists it, so a brand-new container routinely looks orphaned for a moment.
+	MinAge time.Duration
+
+	// Age returns how old a container is. It defaults to the time since the container was created.
+	Age func(types.Container) time.Duration
+
+	// PrefixMatching also matches container IDs that start one another, for pod statuses with truncated IDs.
+	PrefixMatching bool
+}
+
+// Result is what a check found.
+type Result struct {
+	// Containers are all containers the runtime listed, less the excluded ones.
+	Containers []types.Container
+
+	// Checked are the containers in the namespaces checked, or all Containers when none were given.
+	Checked []types.Container
+
+	// KnownIDs are the IDs of the containers the pods account for.
+	KnownIDs []string
+
+	// Orphans are the checked containers no pod accounts for.
+	Orphans []types.Container
+}
+
+// Sources of the listings a check needs.
+const (
+	SourceRuntime = "runtime"
+	SourcePods    = "pods"
+)
+
+// Error is a failure to list the containers or the pods. Either aborts the check: without the full pod list every
+// container would look orphaned, and without the container list there is nothing to compare.
+type Error struct {
+	Source string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Source + ": " + e.Err.Error()
+}
+
+// Run lists the containers and the pods on the node concurrently and compares them. Once either listing fails the
+// other one is cancelled and the failure is returned as an *Error.
+func Run(ctx context.Context, options Options) (Result, error) {
+
+	var result Result
+	var listed []types.Container
+
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	group.Go(func() (err error) {
+		if result.KnownIDs, err = options.Pods.KnownContainerIDs(); err != nil {
+			return &Error{Source: SourcePods, Err: err}
+		}
+		return nil
+	})
+
+	group.Go(func() (err error) {
+		if listed, err = options.Runtime.List(groupCtx); err != nil {
+			return &Error{Source: SourceRuntime, Err: err}
+		}
+		return nil
+	})
+
+	if err := group.Wait(); err != nil {
+		return Result{}, err
+	}
+
+	result.Containers = options.included(listed)
+	result.Checked = result.Containers
+
+	if len(options.Namespaces) > 0 {
+		result.Checked = inNamespaces(result.Containers, options.Namespaces)
+	}
+
+	result.Orphans = options.orphans(result.Checked, result.KnownIDs)
+
+	return result, nil
+
+}
+
+// Compare returns the containers none of the known IDs accounts for, less the excluded ones and those younger than
+// MinAge. Only the matching and age options apply; the containers and IDs are the caller's.
+func Compare(containers []types.Container, known []string, options Options) []types.Container {
+	return options.orphans(options.included(containers), known)
+}
+
+// included returns the containers that are not excluded.
+func (o Options) included(containers []types.Container) []types.Container {
+
+	if o.Exclude == nil {
+		return containers
+	}
+
+	var included []types.Container
+
+	for _, c := range containers {
+		if !o.Exclude(c) {
+			included = append(included, c)
+		}
+	}
+
+	return included
+
+}
+
+// orphans returns the containers none of the known IDs accounts for that are at least MinAge old.
+func (o Options) orphans(containers []types.Container, known []string) []types.Container {
+
+	var orphans []types.Container
+
+	set := NewIDSet(known, o.PrefixMatching)
+
+	for _, c := range containers {
+		if !set.Contains(c.ID) && o.age(c) >= o.MinAge {
+			orphans = append(orphans, c)
+		}
+	}
+
+	return orphans
+
+}
+
+// age returns how old a container is.
+func (o Options) age(c types.Container) time.Duration {
+
+	if o.Age != nil {
+		return o.Age(c)
+	}
+
+	return time.Since(time.Unix(c.Created, 0))
+
+}
+
+// inNamespaces returns the containers whose pod belonged to one of the namespaces.
+func inNamespaces(containers []types.Container, namespaces []string) []types.Container {
+
+	var filtered []types.Container
+
+	for _, c := range containers {
+		for _, namespace := range namespaces {
+			if c.Labels[labelPodNamespace] == namespace {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+
+	return filtered
+
+}
@@ -0,0 +1,128 @@
+package checker
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// testRuntime lists a fixed set of containers, or fails to.
+type testRuntime struct {
+	containers []types.Container
+	err        error
+}
+
+func (r testRuntime) List(ctx context.Context) ([]types.Container, error) {
+	return r.containers, r.err
+}
+
+func (testRuntime) Inspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	return types.ContainerJSON{}, errors.New("not implemented")
+}
+
+func (testRuntime) Stop(ctx context.Context, id string, timeout time.Duration) error {
+	return errors.New("not implemented")
+}
+
+func (testRuntime) Remove(ctx context.Context, id string) error {
+	return errors.New("not implemented")
+}
+
+// testPods knows a fixed set of container IDs, or fails to list them.
+type testPods struct {
+	ids []string
+	err error
+}
+
+func (p testPods) KnownContainerIDs() ([]string, error) {
+	return p.ids, p.err
+}
+
+// testContainer returns an hour-old container with a full-length ID starting with the given characters.
+func testContainer(start string) types.Container {
+	id := start + strings.Repeat("0", 64-len(start))
+	return types.Container{ID: id, Image: "busybox", Created: time.Now().Add(-time.Hour).Unix()}
+}
+
+func TestRun(t *testing.T) {
+
+	running, orphan, young, excluded := testContainer("a1"), testContainer("b2"), testContainer("c3"), testContainer("d4")
+	young.Created = time.Now().Unix()
+	excluded.Image = "k8s.gcr.io/pause:3.1"
+
+	result, err := Run(context.Background(), Options{
+		Runtime: testRuntime{containers: []types.Container{running, orphan, young, excluded}},
+		Pods:    testPods{ids: []string{"docker-id-of-another-node", running.ID}},
+		Exclude: func(c types.Container) bool { return strings.Contains(c.Image, "pause") },
+		MinAge:  3 * time.Minute,
+	})
+
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(result.Containers) != 3 || len(result.Checked) != 3 {
+		t.Errorf("%d containers, %d checked, want 3 of each without the excluded one", len(result.Containers), len(result.Checked))
+	}
+
+	if !reflect.DeepEqual(result.Orphans, []types.Container{orphan}) {
+		t.Errorf("orphans = %v, want only %s", result.Orphans, orphan.ID)
+	}
+
+}
+
+func TestRunNamespaces(t *testing.T) {
+
+	web, db := testContainer("a1"), testContainer("b2")
+	web.Labels = map[string]string{labelPodNamespace: "web"}
+	db.Labels = map[string]string{labelPodNamespace: "db"}
+
+	result, err := Run(context.Background(), Options{
+		Runtime:    testRuntime{containers: []types.Container{web, db}},
+		Pods:       testPods{},
+		Namespaces: []string{"db"},
+	})
+
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(result.Containers) != 2 || !reflect.DeepEqual(result.Orphans, []types.Container{db}) {
+		t.Errorf("%d containers, orphans = %v, want 2 and only %s", len(result.Containers), result.Orphans, db.ID)
+	}
+
+}
+
+func TestRunFailures(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		runtime testRuntime
+		pods    testPods
+		source  string
+	}{
+		{"runtime", testRuntime{err: errors.New("daemon unavailable")}, testPods{}, SourceRuntime},
+		{"pods", testRuntime{containers: []types.Container{testContainer("a1")}}, testPods{err: errors.New("forbidden")}, SourcePods},
+	}
+
+	for _, test := range tests {
+
+		result, err := Run(context.Background(), Options{Runtime: test.runtime, Pods: test.pods})
+
+		if e, ok := err.(*Error); !ok || e.Source != test.source {
+			t.Errorf("%s: error = %v, want one of the %s", test.name, err, test.source)
+		}
+
+		// Without both listings every container would look orphaned.
+		if len(result.Orphans) != 0 {
+			t.Errorf("%s: orphans = %v, want none", test.name, result.Orphans)
+		}
+
+	}
+
+}
@@ -0,0 +1,73 @@
+package checker
+
+import (
+	"strings"
+)
+
+// MinIDPrefix is the shortest ID prefix matched, the length of the Docker CLI's short IDs. Anything shorter is too
+// likely to collide between containers.
+const MinIDPrefix = 12
+
+// IDSet answers whether a Docker container ID belongs to the IDs it was built from. Lookups take constant time in
+// both modes, so the comparison stays cheap on nodes with thousands of containers: prefix mode indexes the IDs by
+// their first MinIDPrefix characters, which any two IDs that match must share.
+type IDSet struct {
+	ids    map[string]bool
+	short  map[string][]string
+	prefix bool
+}
+
+// NormalizeID returns the ID in the form the set stores, as some tools report IDs in upper case or with surrounding
+// whitespace.
+func NormalizeID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// NewIDSet returns the set of the given IDs. With prefix set, it also matches an ID that is a prefix of another, for
+// pod statuses or tools that report IDs truncated to the Docker CLI's short form.
+func NewIDSet(ids []string, prefix bool) IDSet {
+
+	set := IDSet{ids: make(map[string]bool, len(ids)), prefix: prefix}
+
+	if set.prefix {
+		set.short = make(map[string][]string, len(ids))
+	}
+
+	for _, id := range ids {
+
+		id = NormalizeID(id)
+		set.ids[id] = true
+
+		if set.prefix && len(id) >= MinIDPrefix {
+			set.short[id[:MinIDPrefix]] = append(set.short[id[:MinIDPrefix]], id)
+		}
+
+	}
+
+	return set
+
+}
+
+// Contains returns true if the ID is in the set or, in prefix mode, if it and an ID of the set share a prefix of at
+// least MinIDPrefix characters, one being the start of the other.
+func (s IDSet) Contains(id string) bool {
+
+	id = NormalizeID(id)
+
+	if s.ids[id] {
+		return true
+	}
+
+	if !s.prefix || len(id) < MinIDPrefix {
+		return false
+	}
+
+	for _, known := range s.short[id[:MinIDPrefix]] {
+		if strings.HasPrefix(id, known) || strings.HasPrefix(known, id) {
+			return true
+		}
+	}
+
+	return false
+
+}
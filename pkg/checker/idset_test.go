@@ -0,0 +1,107 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIDSet(t *testing.T) {
+
+	full := "4f2a6c1b9e0d" + strings.Repeat("7", 52)
+	other := "4f2a6c1b9e0d" + strings.Repeat("8", 52)
+
+	tests := []struct {
+		name   string
+		prefix bool
+		known  []string
+		id     string
+		want   bool
+	}{
+		{"exact match", false, []string{full}, full, true},
+		{"exact match of another case", false, []string{strings.ToUpper(full)}, " " + full, true},
+		{"exact mode ignores a truncated status", false, []string{full[:12]}, full, false},
+		{"exact mode ignores a truncated listing", false, []string{full}, full[:12], false},
+		{"different ID", false, []string{other}, full, false},
+		{"prefix mode matches exactly", true, []string{full}, full, true},
+		{"truncated status", true, []string{full[:12]}, full, true},
+		{"truncated listing", true, []string{full}, full[:12], true},
+		{"longer prefix", true, []string{full[:20]}, full, true},
+		{"shared short ID, different container", true, []string{other}, full, false},
+		{"shared short ID, different truncation", true, []string{other[:20]}, full, false},
+		{"prefix too short to match", true, []string{full[:8]}, full, false},
+		{"listing too short to match", true, []string{full}, full[:8], false},
+		{"empty set", true, nil, full, false},
+	}
+
+	for _, test := range tests {
+		if got := NewIDSet(test.known, test.prefix).Contains(test.id); got != test.want {
+			t.Errorf("%s: Contains = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+}
+
+// linearContains is the prefix matching as it was before the known IDs were indexed: every known ID is compared.
+func linearContains(ids map[string]bool, id string) bool {
+
+	if ids[id] {
+		return true
+	}
+
+	if len(id) < MinIDPrefix {
+		return false
+	}
+
+	for known := range ids {
+		if len(known) >= MinIDPrefix && (strings.HasPrefix(id, known) || strings.HasPrefix(known, id)) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// BenchmarkMatch compares the linear prefix matching with the indexed one, looking up as many listed containers as
+// there are known IDs. Pod statuses report truncated IDs, and every other container listed is an orphan, so most
+// lookups cannot stop at an exact match.
+func BenchmarkMatch(b *testing.B) {
+
+	for _, n := range []int{100, 1000, 10000} {
+
+		var known, listed []string
+
+		for i := 0; i < n; i++ {
+			sum := sha256.Sum256([]byte(strconv.Itoa(i)))
+			id := hex.EncodeToString(sum[:])
+			if i%2 == 0 {
+				known = append(known, id[:20])
+			}
+			listed = append(listed, id)
+		}
+
+		set := NewIDSet(known, true)
+
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, id := range listed {
+					linearContains(set.ids, id)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, id := range listed {
+					set.Contains(id)
+				}
+			}
+		})
+
+	}
+
+}
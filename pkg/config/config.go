@@ -0,0 +1,644 @@
+// Package config defines the configuration file of dcc, config.yaml, and its built-in defaults.
+package config
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Timing struct {
+	CheckInterval uint32 `yaml:"check_interval"`
+
+	StopTimeout uint32 `yaml:"stop_timeout"`
+
+	SkipMissedTicks bool `yaml:"skip_missed_ticks"`
+
+	TerminatingSlack uint32 `yaml:"terminating_slack"`
+
+	MaxStopTimeout uint32 `yaml:"max_stop_timeout"`
+
+	StopRetry Retry `yaml:"stop_retry"`
+
+	KillOnStopFailure bool `yaml:"kill_on_stop_failure"`
+
+	StopConcurrency int `yaml:"stop_concurrency"`
+
+	SkipUnchanged bool `yaml:"skip_unchanged"`
+
+	FullCycleInterval uint32 `yaml:"full_cycle_interval"`
+
+	StopCooldown Cooldown `yaml:"stop_cooldown"`
+}
+
+type Whitelist struct {
+	Images []string `yaml:"images"`
+}
+
+type Logging struct {
+	SampleEvery int `yaml:"sample_every"`
+
+	Backend string `yaml:"backend"`
+
+	Tag string `yaml:"tag"`
+
+	SyslogNetwork string `yaml:"syslog_network"`
+
+	SyslogAddress string `yaml:"syslog_address"`
+}
+
+type CircuitBreaker struct {
+	MaxErrorCycles int `yaml:"max_error_cycles"`
+
+	ProbeInterval uint32 `yaml:"probe_interval"`
+}
+
+type Timeouts struct {
+	Docker uint32 `yaml:"docker"`
+
+	Kubernetes uint32 `yaml:"kubernetes"`
+
+	Cycle uint32 `yaml:"cycle"`
+
+	Shutdown uint32 `yaml:"shutdown"`
+}
+
+type Watchdog struct {
+	Interval uint32 `yaml:"interval"`
+
+	MaxRSSMB uint64 `yaml:"max_rss_mb"`
+
+	MaxGoroutines int `yaml:"max_goroutines"`
+
+	MaxLoopStall uint32 `yaml:"max_loop_stall"`
+
+	ExitOnBreach bool `yaml:"exit_on_breach"`
+}
+
+type Slack struct {
+	WebhookURLFile string `yaml:"webhook_url_file"`
+
+	Channel string `yaml:"channel"`
+
+	Template string `yaml:"template"`
+}
+
+type Webhook struct {
+	URL string `yaml:"url"`
+
+	SecretFile string `yaml:"secret_file"`
+
+	Headers map[string]string `yaml:"headers"`
+}
+
+type Email struct {
+	SMTPHost string `yaml:"smtp_host"`
+
+	SMTPPort int `yaml:"smtp_port"`
+
+	Username string `yaml:"username"`
+
+	PasswordFile string `yaml:"password_file"`
+
+	From string `yaml:"from"`
+
+	To []string `yaml:"to"`
+
+	Subject string `yaml:"subject"`
+
+	Interval uint32 `yaml:"interval"`
+}
+
+type Opsgenie struct {
+	APIKeyFile string `yaml:"api_key_file"`
+
+	APIURL string `yaml:"api_url"`
+
+	Priority string `yaml:"priority"`
+
+	Tags []string `yaml:"tags"`
+}
+
+type Jira struct {
+	URL string `yaml:"url"`
+
+	Username string `yaml:"username"`
+
+	APITokenFile string `yaml:"api_token_file"`
+
+	Project string `yaml:"project"`
+
+	IssueType string `yaml:"issue_type"`
+
+	MinNodes int `yaml:"min_nodes"`
+
+	Window uint32 `yaml:"window"`
+
+	CheckInterval uint32 `yaml:"check_interval"`
+}
+
+type Sinks struct {
+	Slack Slack `yaml:"slack"`
+
+	Webhook Webhook `yaml:"webhook"`
+
+	Email Email `yaml:"email"`
+
+	Opsgenie Opsgenie `yaml:"opsgenie"`
+}
+
+type RouteMatch struct {
+	Namespace string `yaml:"namespace"`
+
+	Image string `yaml:"image"`
+
+	Labels map[string]string `yaml:"labels"`
+}
+
+type Route struct {
+	Name string `yaml:"name"`
+
+	Match RouteMatch `yaml:"match"`
+
+	Continue bool `yaml:"continue"`
+
+	Sinks `yaml:",inline"`
+}
+
+type LogTail struct {
+	Lines int `yaml:"lines"`
+
+	MaxBytes int `yaml:"max_bytes"`
+}
+
+type Retry struct {
+	Attempts int `yaml:"attempts"`
+
+	InitialDelay uint32 `yaml:"initial_delay"`
+
+	MaxDelay uint32 `yaml:"max_delay"`
+}
+
+type Cooldown struct {
+	InitialDelay uint32 `yaml:"initial_delay"`
+
+	MaxDelay uint32 `yaml:"max_delay"`
+
+	EscalateAfter int `yaml:"escalate_after"`
+}
+
+type DeadLetter struct {
+	Dir string `yaml:"dir"`
+
+	MaxFiles int `yaml:"max_files"`
+}
+
+type Notifications struct {
+	MinFindings int `yaml:"min_findings"`
+
+	Retry Retry `yaml:"retry"`
+
+	DeadLetter DeadLetter `yaml:"dead_letter"`
+
+	LogTail LogTail `yaml:"log_tail"`
+
+	CollectSizes bool `yaml:"collect_sizes"`
+
+	Sinks `yaml:",inline"`
+
+	Routes []Route `yaml:"routes"`
+
+	Jira Jira `yaml:"jira"`
+}
+
+type Summary struct {
+	ConfigMapNamespace string `yaml:"configmap_namespace"`
+
+	ConfigMapName string `yaml:"configmap_name"`
+}
+
+type Upload struct {
+	Provider string `yaml:"provider"`
+
+	Bucket string `yaml:"bucket"`
+
+	Prefix string `yaml:"prefix"`
+
+	Format string `yaml:"format"`
+
+	Period string `yaml:"period"`
+
+	Region string `yaml:"region"`
+
+	Endpoint string `yaml:"endpoint"`
+
+	CredentialsFile string `yaml:"credentials_file"`
+
+	AzureAccount string `yaml:"azure_account"`
+
+	AzureKeyFile string `yaml:"azure_key_file"`
+}
+
+type ReportCRD struct {
+	Namespace string `yaml:"namespace"`
+}
+
+type Export struct {
+	Dir string `yaml:"dir"`
+
+	Format string `yaml:"format"`
+}
+
+type Reports struct {
+	Upload Upload `yaml:"upload"`
+
+	Export Export `yaml:"export"`
+
+	CRD ReportCRD `yaml:"crd"`
+}
+
+type Events struct {
+	Namespace string `yaml:"namespace"`
+
+	InvolvedObject string `yaml:"involved_object"`
+
+	RepeatInterval uint32 `yaml:"repeat_interval"`
+
+	QPS float32 `yaml:"qps"`
+
+	Burst int `yaml:"burst"`
+}
+
+type NodeCondition struct {
+	Type string `yaml:"type"`
+
+	MinOrphans int `yaml:"min_orphans"`
+
+	MinAge uint32 `yaml:"min_age"`
+}
+
+type NodeTaint struct {
+	MaxOrphans int `yaml:"max_orphans"`
+
+	MaxSizeMB int64 `yaml:"max_size_mb"`
+}
+
+type NodeStatus struct {
+	Annotations bool `yaml:"annotations"`
+
+	Condition NodeCondition `yaml:"condition"`
+
+	Taint NodeTaint `yaml:"taint"`
+}
+
+type Kubernetes struct {
+	UseInformer bool `yaml:"use_informer"`
+
+	ResyncPeriod uint32 `yaml:"resync_period"`
+
+	CacheSyncTimeout uint32 `yaml:"cache_sync_timeout"`
+
+	DeletionCheckDelay uint32 `yaml:"deletion_check_delay"`
+
+	PageSize int64 `yaml:"page_size"`
+
+	QPS float32 `yaml:"qps"`
+
+	Burst int `yaml:"burst"`
+
+	Retry Retry `yaml:"retry"`
+
+	IDMatching string `yaml:"id_matching"`
+}
+
+type Kubelet struct {
+	CrossCheck bool `yaml:"cross_check"`
+
+	Address string `yaml:"address"`
+
+	Port int `yaml:"port"`
+
+	TokenFile string `yaml:"token_file"`
+
+	CAFile string `yaml:"ca_file"`
+
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+
+	MaxStatusAge uint32 `yaml:"max_status_age"`
+}
+
+type LeaderElection struct {
+	Enabled bool `yaml:"enabled"`
+
+	Namespace string `yaml:"namespace"`
+
+	LeaseDuration uint32 `yaml:"lease_duration"`
+
+	RenewDeadline uint32 `yaml:"renew_deadline"`
+
+	RetryPeriod uint32 `yaml:"retry_period"`
+}
+
+type Controller struct {
+	Address string `yaml:"address"`
+
+	Listen string `yaml:"listen"`
+
+	Timeout uint32 `yaml:"timeout"`
+
+	MaxRemovalsPerMinute int `yaml:"max_removals_per_minute"`
+
+	ReservationTimeout uint32 `yaml:"reservation_timeout"`
+
+	CAFile string `yaml:"ca_file"`
+
+	CertFile string `yaml:"cert_file"`
+
+	KeyFile string `yaml:"key_file"`
+
+	ServerName string `yaml:"server_name"`
+}
+
+type DanglingContainers struct {
+	Namespace string `yaml:"namespace"`
+
+	RequireApproval bool `yaml:"require_approval"`
+}
+
+type Forensics struct {
+	Dir string `yaml:"dir"`
+
+	Processes bool `yaml:"processes"`
+
+	MaxSnapshots int `yaml:"max_snapshots"`
+
+	Logs bool `yaml:"logs"`
+
+	MaxLogBytes int `yaml:"max_log_bytes"`
+
+	Upload bool `yaml:"upload"`
+
+	Export bool `yaml:"export"`
+
+	MaxExportBytes int64 `yaml:"max_export_bytes"`
+
+	ExportInterval uint32 `yaml:"export_interval"`
+}
+
+type Lock struct {
+	File string `yaml:"file"`
+}
+
+type Hook struct {
+	Name string `yaml:"name"`
+
+	Command []string `yaml:"command"`
+
+	URL string `yaml:"url"`
+
+	Headers map[string]string `yaml:"headers"`
+
+	Timeout uint32 `yaml:"timeout"`
+
+	FailurePolicy string `yaml:"failure_policy"`
+}
+
+type Hooks struct {
+	Pre []Hook `yaml:"pre"`
+
+	Post []Hook `yaml:"post"`
+}
+
+type Filter struct {
+	Name string `yaml:"name"`
+
+	Command []string `yaml:"command"`
+
+	Timeout uint32 `yaml:"timeout"`
+
+	FailurePolicy string `yaml:"failure_policy"`
+}
+
+type Docker struct {
+	QPS float32 `yaml:"qps"`
+
+	Burst int `yaml:"burst"`
+
+	Retry Retry `yaml:"retry"`
+}
+
+type Budget struct {
+	CallInterval uint32 `yaml:"call_interval"`
+
+	MaxCPUPercent uint32 `yaml:"max_cpu_percent"`
+}
+
+type Approval struct {
+	Required bool `yaml:"required"`
+
+	KeyFile string `yaml:"key_file"`
+}
+
+type Drain struct {
+	Policy string `yaml:"policy"`
+
+	Interval uint32 `yaml:"interval"`
+}
+
+type NamespaceDeletion struct {
+	Window uint32 `yaml:"window"`
+
+	Interval uint32 `yaml:"interval"`
+}
+
+type NPD struct {
+	MinOrphans int `yaml:"min_orphans"`
+
+	MaxOutputLength int `yaml:"max_output_length"`
+}
+
+type Maintenance struct {
+	Annotation string `yaml:"annotation"`
+}
+
+type Cluster struct {
+	Name string `yaml:"name"`
+
+	Kubeconfig string `yaml:"kubeconfig"`
+
+	Context string `yaml:"context"`
+
+	DockerPort int `yaml:"docker_port"`
+
+	DockerCAFile string `yaml:"docker_ca_file"`
+
+	DockerCertFile string `yaml:"docker_cert_file"`
+
+	DockerKeyFile string `yaml:"docker_key_file"`
+}
+
+type Heartbeat struct {
+	Namespace string `yaml:"namespace"`
+}
+
+type NodeLabels struct {
+	RemoveRequiresLabel bool `yaml:"remove_requires_label"`
+}
+
+type Sanity struct {
+	MaxOrphanPercent int `yaml:"max_orphan_percent"`
+
+	MinContainers int `yaml:"min_containers"`
+
+	MinSightings int `yaml:"min_sightings"`
+
+	MinAge uint32 `yaml:"min_age"`
+
+	RestartSettle uint32 `yaml:"restart_settle"`
+
+	StateFile string `yaml:"state_file"`
+}
+
+type Metrics struct {
+	Address string `yaml:"address"`
+}
+
+type Probes struct {
+	Address string `yaml:"address"`
+}
+
+type Config struct {
+	Timing Timing `yaml:"timing"`
+
+	Whitelist Whitelist `yaml:"whitelist"`
+
+	Logging Logging `yaml:"logging"`
+
+	Metrics Metrics `yaml:"metrics"`
+
+	Probes Probes `yaml:"probes"`
+
+	Watchdog Watchdog `yaml:"watchdog"`
+
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+
+	Timeouts Timeouts `yaml:"timeouts"`
+
+	Approval Approval `yaml:"approval"`
+
+	Budget Budget `yaml:"budget"`
+
+	Armed bool `yaml:"armed"`
+
+	Forensics Forensics `yaml:"forensics"`
+
+	Lock Lock `yaml:"lock"`
+
+	Hooks Hooks `yaml:"hooks"`
+
+	Filters []Filter `yaml:"filters"`
+
+	Docker Docker `yaml:"docker"`
+
+	Notifications Notifications `yaml:"notifications"`
+
+	Summary Summary `yaml:"summary"`
+
+	Reports Reports `yaml:"reports"`
+
+	Events Events `yaml:"events"`
+
+	NodeStatus NodeStatus `yaml:"node_status"`
+
+	Kubernetes Kubernetes `yaml:"kubernetes"`
+
+	Kubelet Kubelet `yaml:"kubelet"`
+
+	LeaderElection LeaderElection `yaml:"leader_election"`
+
+	Controller Controller `yaml:"controller"`
+
+	DanglingContainers DanglingContainers `yaml:"dangling_containers"`
+
+	Drain Drain `yaml:"drain"`
+
+	NamespaceDeletion NamespaceDeletion `yaml:"namespace_deletion"`
+
+	NPD NPD `yaml:"npd"`
+
+	Maintenance Maintenance `yaml:"maintenance"`
+
+	Clusters []Cluster `yaml:"clusters"`
+
+	Heartbeat Heartbeat `yaml:"heartbeat"`
+
+	NodeLabels NodeLabels `yaml:"node_labels"`
+
+	Profiles map[string]interface{} `yaml:"profiles"`
+
+	Sanity Sanity `yaml:"sanity"`
+}
+
+// Default returns the built-in configuration, which the configuration file is applied over.
+func Default() Config {
+
+	return Config{
+		Timing: Timing{
+			CheckInterval: 90, StopTimeout: 30, TerminatingSlack: 60, MaxStopTimeout: 300,
+			StopRetry: Retry{Attempts: 3, InitialDelay: 2, MaxDelay: 10}, KillOnStopFailure: true,
+			StopCooldown: Cooldown{InitialDelay: 300, MaxDelay: 21600, EscalateAfter: 3}, StopConcurrency: 4,
+			SkipUnchanged: true, FullCycleInterval: 3600,
+		},
+		Logging:  Logging{SampleEvery: 10},
+		Metrics:  Metrics{Address: ":9142"},
+		Probes:   Probes{Address: ":9144"},
+		Watchdog: Watchdog{Interval: 30, MaxGoroutines: 1000, MaxLoopStall: 900},
+		Events:   Events{RepeatInterval: 3600, QPS: 0.2, Burst: 25},
+		Kubelet:  Kubelet{TokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token", MaxStatusAge: 360},
+
+		LeaderElection: LeaderElection{Namespace: "kube-system", LeaseDuration: 30, RenewDeadline: 20, RetryPeriod: 5},
+		Controller:     Controller{Listen: ":9143", Timeout: 10, ReservationTimeout: 300},
+		NodeStatus:     NodeStatus{Condition: NodeCondition{Type: "ContainerGarbagePresent"}},
+		Drain:          Drain{Interval: 15},
+
+		NamespaceDeletion: NamespaceDeletion{Interval: 20},
+		NPD:               NPD{MinOrphans: 1, MaxOutputLength: 80},
+		Maintenance:       Maintenance{Annotation: "dcc.kernelpanek.io/maintenance-windows"},
+		Sanity:            Sanity{MaxOrphanPercent: 50, MinContainers: 10, MinSightings: 2, MinAge: 180, RestartSettle: 120},
+		CircuitBreaker:    CircuitBreaker{MaxErrorCycles: 3, ProbeInterval: 300},
+		Timeouts:          Timeouts{Docker: 30, Kubernetes: 30, Cycle: 600, Shutdown: 25},
+		Budget:            Budget{CallInterval: 50, MaxCPUPercent: 25},
+		Forensics:         Forensics{MaxSnapshots: 200, ExportInterval: 3600},
+		Docker:            Docker{QPS: 20, Burst: 40, Retry: Retry{Attempts: 3, InitialDelay: 1, MaxDelay: 5}},
+		Kubernetes: Kubernetes{
+			UseInformer: true, ResyncPeriod: 600, CacheSyncTimeout: 60, DeletionCheckDelay: 10, PageSize: 500, QPS: 5, Burst: 10,
+			Retry: Retry{Attempts: 3, InitialDelay: 1, MaxDelay: 10},
+		},
+		Notifications: Notifications{
+			Retry:      Retry{Attempts: 4, InitialDelay: 1, MaxDelay: 30},
+			DeadLetter: DeadLetter{MaxFiles: 100},
+			Jira:       Jira{MinNodes: 5, Window: 86400, CheckInterval: 3600},
+		},
+	}
+
+}
+
+// Read returns the configuration file at path applied over the given defaults. On any error the defaults are returned
+// untouched, never a half-applied file.
+func Read(path string, defaults Config) (Config, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return defaults, err
+	}
+
+	loaded := defaults
+
+	if err := yaml.Unmarshal(data, &loaded); err != nil {
+		return defaults, err
+	}
+
+	return loaded, nil
+
+}
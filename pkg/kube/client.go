@@ -0,0 +1,72 @@
+// Package kube reads what the Kubernetes API knows about the containers of a node.
+package kube
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClientOptions configure a Kubernetes client.
+type ClientOptions struct {
+	// Kubeconfig and Context select the cluster when not running in one.
+	Kubeconfig string
+	Context    string
+
+	// QPS and Burst limit the client's request rate.
+	QPS   float32
+	Burst int
+
+	// Timeout fails requests taking longer, unless it is zero.
+	Timeout time.Duration
+}
+
+// NewClient connects to the cluster dcc runs in, or else to the one the kubeconfig file selects.
+func NewClient(options ClientOptions) (*kubernetes.Clientset, error) {
+
+	config, err := NewConfig(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to the cluster: %s", err.Error())
+	}
+
+	return clientset, nil
+
+}
+
+// NewConfig returns the configuration of clients for the cluster dcc runs in, or else for the one the kubeconfig
+// file selects.
+func NewConfig(options ClientOptions) (*rest.Config, error) {
+
+	config, err := rest.InClusterConfig()
+
+	if err != nil {
+		var configOverrides = clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{Server: ""}, Context: clientcmdapi.Context{Cluster: options.Context}}
+
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: options.Kubeconfig},
+			&configOverrides).ClientConfig()
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot configure the Kubernetes client: %s", err.Error())
+		}
+	}
+
+	config.QPS = options.QPS
+	config.Burst = options.Burst
+	config.Timeout = options.Timeout
+
+	return config, nil
+
+}
@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RuntimeDocker is the container runtime dcc inspects; pod statuses name it in their container IDs.
+const RuntimeDocker = "docker"
+
+var (
+	runtimesMutex sync.Mutex
+	runtimesSeen  = map[string]bool{}
+)
+
+// ParseContainerID splits a container ID from a pod status, such as docker://<id>, containerd://<id> or
+// cri-o://<id>, into its runtime and the runtime's own ID. IDs without a scheme are taken as Docker's.
+func ParseContainerID(uri string) (runtime, id string) {
+
+	if i := strings.Index(uri, "://"); i >= 0 {
+		return uri[:i], uri[i+3:]
+	}
+
+	return RuntimeDocker, uri
+
+}
+
+// ownedByDocker returns the Docker ID of a container from a pod status, or false if another runtime owns it. The
+// first container seen of every other runtime is logged, as dcc cannot check those.
+func ownedByDocker(uri string) (string, bool) {
+
+	runtime, id := ParseContainerID(uri)
+
+	if runtime == RuntimeDocker {
+		return id, true
+	}
+
+	runtimesMutex.Lock()
+	defer runtimesMutex.Unlock()
+
+	if !runtimesSeen[runtime] {
+		runtimesSeen[runtime] = true
+		log.Println("Pods on this node run containers in the", runtime, "runtime, which is not checked.")
+	}
+
+	return "", false
+
+}
+
+// PodContainerIDs returns the Docker IDs of the pod's containers, including its init containers: native sidecars
+// are init containers that keep running, and finished init containers may still exist in the runtime. Containers
+// of other runtimes are left out, since they can never match a Docker container.
+func PodContainerIDs(pod *v1.Pod) []string {
+
+	var containerIDs []string
+
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if containerID, ok := ownedByDocker(status.ContainerID); ok && containerID != "" {
+				containerIDs = append(containerIDs, containerID)
+			}
+		}
+	}
+
+	return containerIDs
+
+}
+
+// NodePods lists the pods scheduled to a node from the apiserver to learn their containers. It is the checker's
+// source of pods when there is no local cache of them.
+type NodePods struct {
+	Client kubernetes.Interface
+	Node   string
+
+	// PageSize lists the pods in pages of that many, so even very dense nodes never need the whole list in one
+	// response. 0 lists them all at once.
+	PageSize int64
+
+	// Call makes every request to the apiserver, named by its operation, and may add retries or metrics around it.
+	// Requests are made as they are when it is nil.
+	Call func(operation string, call func() error) error
+}
+
+// KnownContainerIDs returns the Docker IDs of the containers of all pods on the node.
+func (n *NodePods) KnownContainerIDs() ([]string, error) {
+
+	var containerIDs []string
+
+	options := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", n.Node).String(),
+		Limit:         n.PageSize,
+	}
+
+	for {
+
+		var podList *v1.PodList
+		list := func() (err error) {
+			podList, err = n.Client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+			return err
+		}
+
+		var err error
+		if n.Call != nil {
+			err = n.Call("ListPods", list)
+		} else {
+			err = list()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range podList.Items {
+			containerIDs = append(containerIDs, PodContainerIDs(&podList.Items[i])...)
+		}
+
+		if podList.Continue == "" {
+			break
+		}
+
+		options.Continue = podList.Continue
+
+	}
+
+	return containerIDs, nil
+
+}
@@ -0,0 +1,67 @@
+package kube
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseContainerID(t *testing.T) {
+
+	tests := []struct {
+		uri, runtime, id string
+	}{
+		{"docker://4f2a6c1b9e0d", "docker", "4f2a6c1b9e0d"},
+		{"containerd://4f2a6c1b9e0d", "containerd", "4f2a6c1b9e0d"},
+		{"cri-o://4f2a6c1b9e0d", "cri-o", "4f2a6c1b9e0d"},
+		{"4f2a6c1b9e0d", "docker", "4f2a6c1b9e0d"},
+		{"", "docker", ""},
+	}
+
+	for _, test := range tests {
+		if runtime, id := ParseContainerID(test.uri); runtime != test.runtime || id != test.id {
+			t.Errorf("ParseContainerID(%q) = %q, %q, want %q, %q", test.uri, runtime, id, test.runtime, test.id)
+		}
+	}
+
+}
+
+func TestNodePods(t *testing.T) {
+
+	pod := func(name, node, containerID string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1.PodSpec{NodeName: node},
+			Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "app", ContainerID: containerID}}},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		pod("web-1", "node-a", "docker://a1"),
+		pod("web-2", "node-a", "containerd://b2"),
+	)
+
+	var calls []string
+	pods := &NodePods{Client: client, Node: "node-a", Call: func(operation string, call func() error) error {
+		calls = append(calls, operation)
+		return call()
+	}}
+
+	ids, err := pods.KnownContainerIDs()
+
+	if err != nil {
+		t.Fatalf("KnownContainerIDs: %v", err)
+	}
+
+	// The containerd container can never match a Docker container.
+	if len(ids) != 1 || ids[0] != "a1" {
+		t.Errorf("IDs = %v, want only the Docker container a1", ids)
+	}
+
+	if len(calls) != 1 || calls[0] != "ListPods" {
+		t.Errorf("calls = %v, want one ListPods", calls)
+	}
+
+}
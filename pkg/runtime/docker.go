@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// Docker is the Runtime of a Docker daemon.
+type Docker struct {
+	Client *docker.Client
+
+	// Sizes has List report the size of each container's writable layer, which the daemon computes at some cost.
+	Sizes bool
+
+	// Call makes every call to the daemon, named by its API method, and may add deadlines, retries or metrics around
+	// it. Calls are made as they are when it is nil.
+	Call func(ctx context.Context, method string, call func(ctx context.Context) error) error
+}
+
+// call makes a call to the daemon through Call.
+func (d *Docker) call(ctx context.Context, method string, call func(ctx context.Context) error) error {
+
+	if d.Call == nil {
+		return call(ctx)
+	}
+
+	return d.Call(ctx, method, call)
+
+}
+
+func (d *Docker) List(ctx context.Context) ([]types.Container, error) {
+
+	var containers []types.Container
+
+	err := d.call(ctx, "ContainerList", func(ctx context.Context) (err error) {
+		containers, err = d.Client.ContainerList(ctx, types.ContainerListOptions{Size: d.Sizes})
+		return err
+	})
+
+	return containers, err
+
+}
+
+func (d *Docker) Inspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+
+	var inspect types.ContainerJSON
+
+	err := d.call(ctx, "ContainerInspect", func(ctx context.Context) (err error) {
+		inspect, err = d.Client.ContainerInspect(ctx, id)
+		return err
+	})
+
+	return inspect, err
+
+}
+
+// Stop has the daemon stop the container, which it kills once the timeout has passed.
+func (d *Docker) Stop(ctx context.Context, id string, timeout time.Duration) error {
+
+	return d.call(ctx, "ContainerStop", func(ctx context.Context) error {
+		return d.Client.ContainerStop(ctx, id, &timeout)
+	})
+
+}
+
+func (d *Docker) Remove(ctx context.Context, id string) error {
+
+	return d.call(ctx, "ContainerRemove", func(ctx context.Context) error {
+		return d.Client.ContainerRemove(ctx, id, types.ContainerRemoveOptions{})
+	})
+
+}
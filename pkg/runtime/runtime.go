@@ -0,0 +1,26 @@
+// Package runtime is the container runtime of a node, whose containers dcc checks against the node's pods.
+package runtime
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// Runtime is the container runtime whose containers are checked against the orchestrator. A check only lists,
+// inspects and stops containers through it, so other runtimes, or a fake, can stand in for Docker. Containers are
+// described with Docker's API types, which another runtime translates its own into.
+type Runtime interface {
+	// List returns the running containers.
+	List(ctx context.Context) ([]types.Container, error)
+
+	// Inspect returns the current state of a container.
+	Inspect(ctx context.Context, id string) (types.ContainerJSON, error)
+
+	// Stop stops a container, giving it the timeout to exit, and returns nil only once it no longer runs.
+	Stop(ctx context.Context, id string, timeout time.Duration) error
+
+	// Remove deletes a stopped container.
+	Remove(ctx context.Context, id string) error
+}
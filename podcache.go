@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kernelpanek/dcc/pkg/kube"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// podInformer keeps a local cache of the pods scheduled to this node. It is nil when the informer is disabled and
+// pods are listed every cycle instead.
+var podInformer cache.SharedIndexInformer
+
+var (
+	// informerStop is closed on shutdown to stop the pod informer.
+	informerStop     = make(chan struct{})
+	informerStopOnce sync.Once
+)
+
+var (
+	deletionMutex sync.Mutex
+	deletionTimer *time.Timer
+
+	// deletedPods remembers recently deleted pods by UID: their termination grace period, for stopping their
+	// orphaned containers the way the kubelet would have, and their owners, to attribute the orphans to workloads.
+	deletedPods = map[types.UID]deletedPod{}
+)
+
+// deletedPodMemory is how long a deleted pod is remembered.
+const deletedPodMemory = time.Hour
+
+// deletedPod is what is remembered of a deleted pod.
+type deletedPod struct {
+	grace   *time.Duration
+	owners  []metav1.OwnerReference
+	deleted time.Time
+}
+
+// startPodInformer starts watching the pods on this node and waits up to cache_sync_timeout seconds for the initial
+// list to arrive. Until it has, cycles list the pods from the apiserver instead.
+func startPodInformer() {
+
+	if !config.Kubernetes.UseInformer {
+		return
+	}
+
+	listWatch := cache.NewListWatchFromClient(watchClient.CoreV1().RESTClient(), "pods", v1.NamespaceAll,
+		fields.OneTermEqualSelector("spec.nodeName", nodeFlag))
+
+	podInformer = cache.NewSharedIndexInformer(listWatch, &v1.Pod{}, time.Duration(config.Kubernetes.ResyncPeriod)*time.Second, cache.Indexers{})
+
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: rememberDeletedPod,
+	})
+
+	go podInformer.Run(informerStop)
+
+	timeout := time.Duration(config.Kubernetes.CacheSyncTimeout) * time.Second
+	syncStop := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(syncStop) })
+
+	log.Println("Waiting for the pod cache to sync.")
+
+	if !cache.WaitForCacheSync(syncStop, podInformer.HasSynced) {
+		log.Println("Pod cache not synced within", timeout.String()+", listing pods from the apiserver until it is.")
+		sendEvent("PodCacheSyncTimeout", fmt.Sprintf("Pod cache not synced within %s, listing pods from the apiserver until it is", timeout))
+		return
+	}
+
+	timer.Stop()
+	log.Println("Pod cache synced.")
+
+}
+
+// stopPodInformer stops the pod informer.
+func stopPodInformer() {
+	informerStopOnce.Do(func() { close(informerStop) })
+}
+
+// podCacheReady returns true when the pods can be read from the informer cache.
+func podCacheReady() bool {
+	return podInformer != nil && podInformer.HasSynced()
+}
+
+// cachedPodContainers returns the container IDs of the pods in the informer cache.
+func cachedPodContainers() ([]string, error) {
+
+	if !podInformer.HasSynced() {
+		return nil, fmt.Errorf("pod cache has not synced")
+	}
+
+	var containerIDs []string
+
+	for _, object := range podInformer.GetStore().List() {
+		if pod, ok := object.(*v1.Pod); ok {
+			containerIDs = append(containerIDs, kube.PodContainerIDs(pod)...)
+		}
+	}
+
+	return containerIDs, nil
+
+}
+
+// rememberDeletedPod records the termination grace period and the owner references of a pod the informer saw deleted.
+func rememberDeletedPod(object interface{}) {
+
+	if tombstone, ok := object.(cache.DeletedFinalStateUnknown); ok {
+		object = tombstone.Obj
+	}
+
+	pod, ok := object.(*v1.Pod)
+
+	if !ok {
+		return
+	}
+
+	deletionMutex.Lock()
+	defer deletionMutex.Unlock()
+
+	now := time.Now()
+
+	for uid, d := range deletedPods {
+		if now.Sub(d.deleted) > deletedPodMemory {
+			delete(deletedPods, uid)
+		}
+	}
+
+	d := deletedPod{owners: pod.OwnerReferences, deleted: now}
+
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		grace := time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+		d.grace = &grace
+	}
+
+	deletedPods[pod.UID] = d
+
+}
+
+// rememberedDeletedPod returns what is remembered of a recently deleted pod.
+func rememberedDeletedPod(uid string) (deletedPod, bool) {
+
+	deletionMutex.Lock()
+	defer deletionMutex.Unlock()
+
+	d, ok := deletedPods[types.UID(uid)]
+
+	return d, ok
+
+}
+
+// schedulePodDeletionCheck requests a check once deletion_check_delay has passed without further pod deletions, so
+// a burst of deletions results in a single check.
+func schedulePodDeletionCheck(queue workqueue.Interface) {
+
+	deletionMutex.Lock()
+	defer deletionMutex.Unlock()
+
+	if deletionTimer != nil {
+		deletionTimer.Stop()
+	}
+
+	deletionTimer = time.AfterFunc(time.Duration(config.Kubernetes.DeletionCheckDelay)*time.Second, func() {
+		log.Println("Checking after pod deletion.")
+		queue.Add(fullCheckRequest())
+	})
+
+}
+
+// nodePods returns the pods scheduled to this node, from the informer cache once it has synced or else from the
+// apiserver.
+func nodePods() ([]*v1.Pod, error) {
+
+	var pods []*v1.Pod
+
+	if podCacheReady() {
+
+		for _, object := range podInformer.GetStore().List() {
+			if pod, ok := object.(*v1.Pod); ok {
+				pods = append(pods, pod)
+			}
+		}
+
+		return pods, nil
+
+	}
+
+	return listNodePods()
+
+}
+
+// listNodePods lists the pods on the node from the apiserver, bypassing the pod cache.
+func listNodePods() ([]*v1.Pod, error) {
+
+	var pods []*v1.Pod
+
+	var podList *v1.PodList
+	err := retryAPI("ListPods", func() (err error) {
+		started := time.Now()
+		podList, err = kubeClient.CoreV1().Pods(v1.NamespaceAll).List(metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeFlag).String(),
+		})
+		observeCall("kubernetes", "ListPods", started, err)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+	}
+
+	return pods, nil
+
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// retryJitter is the fraction of each backoff delay added at random, so a large fleet that failed together does
+// not retry together.
+const retryJitter = 0.5
+
+// withRetry runs a call to an external system until it succeeds, fails with an error retriable rejects, or has been
+// attempted as many times as the call type's retry settings allow. The delay between attempts starts at
+// initial_delay, doubles up to max_delay and is jittered. Retries stop once the context is done. The last error is
+// returned, for the caller to act on rather than carry on with what it has.
+func withRetry(ctx context.Context, retry dccconfig.Retry, retriable func(error) bool, call string, fn func() error) error {
+
+	delay := time.Duration(retry.InitialDelay) * time.Second
+	maxDelay := time.Duration(retry.MaxDelay) * time.Second
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+
+		if err = fn(); err == nil || !retriable(err) || attempt >= retry.Attempts || ctx.Err() != nil {
+			return err
+		}
+
+		pause := wait.Jitter(delay, retryJitter)
+		log.Println(call, "failed, retrying in", pause.Round(time.Millisecond), ":", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(pause):
+		}
+
+		delay *= 2
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+
+	}
+
+}
+
+// retryAlways treats every failure as worth retrying.
+func retryAlways(error) bool {
+	return true
+}
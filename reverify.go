@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/kernelpanek/dcc/pkg/kube"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reverifyOrphan checks an orphan once more right before it is stopped, as the comparison that classified it may be
+// a while old by then. It returns the reason to leave the container alone if it is restarting or restarted within
+// restart_settle seconds, if someone is running a command in it (on Docker), or if a pod on the node has claimed it
+// since; down is set if the container has already exited, is being removed or is gone, so there is nothing left to
+// stop. The pod is read from the apiserver, not the pod cache.
+func reverifyOrphan(ctx context.Context, rt dccruntime.Runtime, c types.Container) (reason string, down bool) {
+
+	inspect, err := rt.Inspect(ctx, c.ID)
+
+	switch {
+	case docker.IsErrContainerNotFound(err):
+		return "container is gone", true
+	case err != nil:
+		return "container cannot be re-inspected: " + err.Error(), false
+	case inspect.ContainerJSONBase == nil || inspect.ID != c.ID:
+		return "container changed since it was listed", false
+	case inspect.State == nil:
+		return "container state unknown", false
+	case inspect.State.RemovalInProgress || inspect.State.Dead:
+		return "container is being removed", true
+	case !inspect.State.Running && !inspect.State.Restarting:
+		return "container already exited", true
+	}
+
+	if reason := restartVeto(inspect, time.Now()); reason != "" {
+		return reason, false
+	}
+
+	if cli := dockerClientOf(rt); cli != nil {
+		if reason := execVeto(ctx, cli, inspect); reason != "" {
+			return reason, false
+		}
+	}
+
+	return podClaimVeto(c), false
+
+}
+
+// podClaimVeto returns why an orphan a pod on the node lists by now is left alone. The kubelet labels every container
+// with its pod, so only that one pod is read instead of listing all of the node's pods for every orphan.
+func podClaimVeto(c types.Container) string {
+
+	namespace, name := c.Labels[labelPodNamespace], c.Labels[labelPodName]
+
+	if namespace == "" || name == "" {
+		return ""
+	}
+
+	var pod *v1.Pod
+	err := retryAPI("GetPod", func() (err error) {
+		started := time.Now()
+		pod, err = kubeClient.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		observeCall("kubernetes", "GetPod", started, err)
+		return err
+	})
+
+	switch {
+	case errors.IsNotFound(err):
+		return ""
+	case err != nil:
+		return "pod cannot be re-read: " + err.Error()
+	case pod.Spec.NodeName == nodeFlag && newContainerIDSet(kube.PodContainerIDs(pod)).Contains(c.ID):
+		return "pod " + pod.Namespace + "/" + pod.Name + " now lists the container"
+	}
+
+	return ""
+
+}
+
+// restartVeto returns why a container that is restarting, or restarted only moments ago, is left alone this cycle:
+// the kubelet or the runtime may be recreating or adopting it right now.
+func restartVeto(inspect types.ContainerJSON, now time.Time) string {
+
+	if inspect.State.Restarting {
+		return "container is restarting"
+	}
+
+	settle := time.Duration(config.Sanity.RestartSettle) * time.Second
+
+	if inspect.RestartCount == 0 || settle <= 0 {
+		return ""
+	}
+
+	startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+
+	if err == nil && now.Sub(startedAt) < settle {
+		return fmt.Sprintf("container restarted %s ago", now.Sub(startedAt).Round(time.Second))
+	}
+
+	return ""
+
+}
+
+// execVeto returns why a container with exec sessions still running is left alone: someone is most likely debugging
+// inside it. Docker keeps finished sessions around for a while, so each one is inspected. Docker does not expose
+// attach sessions, so those cannot be detected.
+func execVeto(ctx context.Context, cli *docker.Client, inspect types.ContainerJSON) string {
+
+	running := 0
+
+	for _, id := range inspect.ExecIDs {
+
+		execCtx, cancel := dockerContext(ctx, 0)
+		started := time.Now()
+		session, err := cli.ContainerExecInspect(execCtx, id)
+		observeCall("docker", "ContainerExecInspect", started, err)
+		cancel()
+
+		// A session that cannot be inspected may still be running.
+		if err != nil || session.Running {
+			running++
+		}
+
+	}
+
+	if running > 0 {
+		return fmt.Sprintf("%d exec sessions in progress", running)
+	}
+
+	return ""
+
+}
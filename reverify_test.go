@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReverifyOrphan(t *testing.T) {
+
+	running, stopped, adopted, gone := testID("a1"), testID("b2"), testID("c3"), testID("d4")
+
+	// The kubelet labels a container with its pod, which is read again.
+	claimed := testContainer(adopted)
+	claimed.Labels = map[string]string{labelPodNamespace: "default", labelPodName: "web-1"}
+
+	rt := newFakeRuntime(testContainer(running), testContainer(stopped), claimed)
+	rt.running[stopped] = false
+	defer useFakes(rt, &fakeOrchestrator{})()
+
+	savedClient := kubeClient
+	defer func() { kubeClient = savedClient }()
+
+	kubeClient = fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: nodeFlag},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{Name: "web", ContainerID: "docker://" + adopted}},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		id     string
+		down   bool
+		reason bool
+	}{
+		{"still an orphan", running, false, false},
+		{"already exited", stopped, true, true},
+		{"claimed by a pod since", adopted, false, true},
+		{"gone", gone, true, true},
+	}
+
+	for _, test := range tests {
+
+		c := testContainer(test.id)
+		if test.id == adopted {
+			c = claimed
+		}
+
+		reason, down := reverifyOrphan(context.Background(), rt, c)
+
+		if down != test.down || (reason != "") != test.reason {
+			t.Errorf("%s: reverifyOrphan = (%q, %v), want down %v and a reason %v", test.name, reason, down, test.down, test.reason)
+		}
+
+	}
+
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// The controller protocol is plain gRPC with JSON-encoded messages, so that neither side needs generated stubs.
+const (
+	rpcService   = "dcc.v1.Controller"
+	rpcAuthorize = "/" + rpcService + "/Authorize"
+	rpcReport    = "/" + rpcService + "/Report"
+	rpcConfirm   = "/" + rpcService + "/Confirm"
+	rpcRelease   = "/" + rpcService + "/Release"
+)
+
+// jsonCodec encodes gRPC messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) String() string {
+	return "json"
+}
+
+// authorizeRequest asks the controller whether an agent may stop an orphan.
+type authorizeRequest struct {
+	Node    string  `json:"node"`
+	Finding finding `json:"finding"`
+}
+
+// authorizeResponse carries the controller's decision. A denied removal is deferred with the given reason; an allowed
+// one may come with a reservation of the removal budget, to be confirmed or released.
+type authorizeResponse struct {
+	Allowed     bool   `json:"allowed"`
+	Reason      string `json:"reason,omitempty"`
+	Reservation string `json:"reservation,omitempty"`
+}
+
+// settleRequest tells the controller whether an agent stopped an orphan it authorized, by confirming or releasing its
+// reservation.
+type settleRequest struct {
+	Node        string  `json:"node"`
+	Reservation string  `json:"reservation"`
+	Finding     finding `json:"finding"`
+}
+
+// reportAck acknowledges a cycle report or a settled reservation.
+type reportAck struct{}
+
+// controllerServer is implemented by the controller.
+type controllerServer interface {
+	Authorize(ctx context.Context, request *authorizeRequest) (*authorizeResponse, error)
+	Report(ctx context.Context, report *cycleReport) (*reportAck, error)
+	Confirm(ctx context.Context, request *settleRequest) (*reportAck, error)
+	Release(ctx context.Context, request *settleRequest) (*reportAck, error)
+}
+
+var controllerServiceDesc = grpc.ServiceDesc{
+	ServiceName: rpcService,
+	HandlerType: (*controllerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Authorize",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := new(authorizeRequest)
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				return srv.(controllerServer).Authorize(ctx, request)
+			},
+		},
+		{
+			MethodName: "Report",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				report := new(cycleReport)
+				if err := dec(report); err != nil {
+					return nil, err
+				}
+				return srv.(controllerServer).Report(ctx, report)
+			},
+		},
+		{
+			MethodName: "Confirm",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := new(settleRequest)
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				return srv.(controllerServer).Confirm(ctx, request)
+			},
+		},
+		{
+			MethodName: "Release",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := new(settleRequest)
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				return srv.(controllerServer).Release(ctx, request)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// controllerTLS returns the TLS configuration of either end of the controller protocol. Both ends present their
+// certificate and only accept one signed by the configured CA, so no one else can report to the controller or have
+// removals approved, and agents only take decisions from the real controller.
+func controllerTLS() (*tls.Config, error) {
+
+	c := config.Controller
+
+	if c.CAFile == "" || c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("controller ca_file, cert_file and key_file are required: the controller and its agents only talk over mutual TLS")
+	}
+
+	pem, err := ioutil.ReadFile(c.CAFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", c.CAFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ServerName:   c.ServerName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+
+}
+
+// controllerConn is the agent's connection to the controller, dialed lazily by the first call.
+var controllerConn *grpc.ClientConn
+
+// callController invokes a controller method within the configured timeout.
+func callController(method string, request, response interface{}) error {
+
+	if controllerConn == nil {
+		tlsConfig, err := controllerTLS()
+		if err != nil {
+			return err
+		}
+		conn, err := grpc.Dial(config.Controller.Address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), grpc.WithCodec(jsonCodec{}))
+		if err != nil {
+			return err
+		}
+		controllerConn = conn
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Controller.Timeout)*time.Second)
+	defer cancel()
+
+	started := time.Now()
+	err := grpc.Invoke(ctx, method, request, response, controllerConn)
+	observeCall("controller", method, started, err)
+
+	return err
+
+}
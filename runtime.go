@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+
+	docker "github.com/docker/docker/client"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+)
+
+// dockerRuntime is the local Docker daemon. Its stops go through stopContainer, which retries them, confirms the
+// container no longer runs and kills it as a last resort.
+type dockerRuntime struct {
+	*dccruntime.Docker
+}
+
+// newContainerRuntime returns the runtime of the node for a cycle. Like kubeClient, kubeRecorder and
+// nodeOrchestrator it is a variable, so fakes such as fakeRuntime, client-go's fake clientset and record's
+// FakeRecorder can be injected in their place.
+var newContainerRuntime = newDockerRuntime
+
+// newDockerRuntime returns the local Docker daemon, through the process's shared client.
+func newDockerRuntime() (dccruntime.Runtime, error) {
+
+	cli, err := sharedDockerClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerRuntime{&dccruntime.Docker{Client: cli, Sizes: config.Notifications.CollectSizes, Call: dockerCall}}, nil
+
+}
+
+// dockerCall makes a call to the daemon within the docker timeout and observes it. Calls other than removals retry
+// an unreachable or slow daemon as set by the docker retry settings; a removal that timed out may still go through.
+func dockerCall(ctx context.Context, method string, call func(ctx context.Context) error) error {
+
+	attempt := func() error {
+		callCtx, cancel := dockerContext(ctx, 0)
+		defer cancel()
+
+		started := time.Now()
+		err := call(callCtx)
+		observeCall("docker", method, started, err)
+		return err
+	}
+
+	if method == "ContainerRemove" {
+		return attempt()
+	}
+
+	return withRetry(ctx, config.Docker.Retry, retriableDockerError, "Docker call "+method, attempt)
+
+}
+
+func (d *dockerRuntime) Stop(ctx context.Context, id string, timeout time.Duration) error {
+	return stopContainer(ctx, d.Client, id, timeout)
+}
+
+// dockerClientOf returns the Docker client behind the runtime, for the checks and forensics only Docker supports:
+// exec sessions, logs, processes and filesystem exports. It returns nil for other runtimes, which skip those.
+func dockerClientOf(rt dccruntime.Runtime) *docker.Client {
+
+	if d, ok := rt.(*dockerRuntime); ok {
+		return d.Client
+	}
+
+	return nil
+
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+)
+
+// Labels the kubelet's Docker integration uses to tie a pod's containers to its sandbox, the pause container that
+// holds the pod's network namespace.
+const (
+	labelContainerType   = "io.kubernetes.docker.type"
+	labelSandboxID       = "io.kubernetes.sandbox.id"
+	containerTypeSandbox = "podsandbox"
+)
+
+// sandboxGuard keeps a pod sandbox running while any container that joined it still runs: stopping the sandbox
+// would take the network of all of them down. A sandbox left by its containers is stopped in a later cycle. The
+// containers are listed afresh from the cycle's runtime, across all namespaces, since a scoped cycle only lists some
+// of them.
+func sandboxGuard(ctx context.Context, rt dccruntime.Runtime, failures *cycleFailures) removalGuard {
+
+	containers, err := rt.List(ctx)
+
+	if err != nil {
+		failures.add("sandbox-siblings", err)
+		return func(c types.Container, owner podOwner) string {
+			if c.Labels[labelContainerType] == containerTypeSandbox {
+				return "containers of the sandbox cannot be listed"
+			}
+			return ""
+		}
+	}
+
+	siblings := map[string]int{}
+
+	for _, c := range containers {
+		if sandbox := c.Labels[labelSandboxID]; sandbox != "" && sandbox != c.ID {
+			siblings[sandbox]++
+		}
+	}
+
+	return func(c types.Container, owner podOwner) string {
+
+		if c.Labels[labelContainerType] != containerTypeSandbox {
+			return ""
+		}
+
+		if running := siblings[c.ID]; running > 0 {
+			return fmt.Sprintf("sandbox still shared by %d running containers", running)
+		}
+
+		return ""
+
+	}
+
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// stageMassOrphans is the failure stage of a cycle with implausibly many orphans.
+const stageMassOrphans = "mass-orphans"
+
+// checkMassOrphans flags a cycle in which more than max_orphan_percent of the node's containers look orphaned. Such a
+// result almost always means the data is wrong, e.g. a stale or truncated pod list, rather than a genuine mass leak,
+// so the cycle's removals are held and the failure is reported loudly.
+func checkMassOrphans(orphans, containers []types.Container, failures *cycleFailures) {
+
+	limit := config.Sanity.MaxOrphanPercent
+
+	if limit <= 0 || len(containers) < config.Sanity.MinContainers || len(containers) == 0 {
+		return
+	}
+
+	percent := 100 * len(orphans) / len(containers)
+
+	if percent <= limit {
+		return
+	}
+
+	err := fmt.Errorf("%d of %d containers (%d%%) look orphaned, more than %d%%; holding all removals", len(orphans), len(containers), percent, limit)
+	log.Println("Mass orphan detection:", err.Error())
+	failures.add(stageMassOrphans, err)
+	sendEvent("MassOrphansDetected", err.Error())
+
+}
+
+// massOrphanGuard holds every removal of a cycle with implausibly many orphans.
+func massOrphanGuard(types.Container, podOwner) string {
+	return "too many containers look orphaned to trust the data"
+}
+
+// containerFirstSeen holds when dcc first listed each container. Go keeps a monotonic clock reading in these times, so
+// the time since is immune to steps of the wall clock.
+var containerFirstSeen = map[string]time.Time{}
+
+// observeContainers records the containers listed in this cycle. Only a cycle that listed every container forgets
+// the ones that are gone.
+func observeContainers(containers []types.Container, complete bool) {
+
+	now := time.Now()
+	seen := make(map[string]time.Time, len(containers))
+
+	if !complete {
+		for id, first := range containerFirstSeen {
+			seen[id] = first
+		}
+	}
+
+	for _, c := range containers {
+		if first, ok := containerFirstSeen[c.ID]; ok {
+			seen[c.ID] = first
+		} else {
+			seen[c.ID] = now
+		}
+	}
+
+	containerFirstSeen = seen
+
+}
+
+// containerAge returns how old a container is, for the checker to leave those younger than min_age alone. The
+// creation time comes from the node's wall clock, which an NTP step or a VM resume can throw off, so the age is also
+// bounded by how long dcc has been watching the container, and the lower of the two counts.
+func containerAge(c types.Container) time.Duration {
+
+	age := time.Since(time.Unix(c.Created, 0))
+
+	if first, ok := containerFirstSeen[c.ID]; ok {
+		if watched := time.Since(first); watched < age {
+			age = watched
+		}
+	}
+
+	return age
+
+}
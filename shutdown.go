@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// shutdownRequested is closed when a graceful shutdown starts.
+	shutdownRequested = make(chan struct{})
+
+	shuttingDown int32
+	shutdownOnce sync.Once
+)
+
+// watchSignals starts a graceful shutdown on the first SIGTERM or SIGINT. A second signal makes dcc exit at once.
+func watchSignals() {
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	received := <-signals
+	requestShutdown("Received " + received.String())
+
+	received = <-signals
+	log.Println("Received", received.String(), "again, exiting now.")
+	os.Exit(1)
+
+}
+
+// requestShutdown starts a graceful shutdown for the given reason. If the check loop has not wound down within the
+// shutdown timeout, dcc exits at once.
+func requestShutdown(reason string) {
+
+	shutdownOnce.Do(func() {
+
+		log.Println(reason + ", shutting down.")
+		atomic.StoreInt32(&shuttingDown, 1)
+		close(shutdownRequested)
+
+		timeout := time.Duration(config.Timeouts.Shutdown) * time.Second
+
+		time.AfterFunc(timeout, func() {
+			log.Println("Shutdown did not finish within", timeout.String()+", exiting now.")
+			os.Exit(1)
+		})
+
+	})
+
+}
+
+// isShuttingDown returns true once a shutdown was requested. Cycles check it before every removal, so the stop in
+// progress completes but no new one starts.
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// shutdown stops the pod informer and records what is still queued once the check loop has stopped: the pending
+// events, the queued notifications and the summaries of notifiers that batch their reports.
+func shutdown() {
+
+	timeout := time.Duration(config.Timeouts.Shutdown) * time.Second
+
+	stopPodInformer()
+
+	dispatcher.shutdown(timeout)
+	drainNotifiers(timeout)
+	flushNotifiers()
+
+	log.Println("Shutdown complete.")
+
+}
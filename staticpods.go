@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types"
+)
+
+// labelConfigSource is how the kubelet's Docker integration records a pod's config source annotation on its sandbox.
+// Static pods come from the "file" or "http" sources.
+const labelConfigSource = "annotation.kubernetes.io/config.source"
+
+// excludeStaticPods drops the containers of static pods from the orphans. Their statuses reach the apiserver through
+// mirror pods, which can lag or be missing altogether, so the pod list alone cannot tell that they are orphaned. Only
+// sandboxes carry the config source, so an app container's is looked up on its sandbox among the listed containers.
+func excludeStaticPods(orphans, containers []types.Container, failures *cycleFailures) []types.Container {
+
+	if len(orphans) == 0 {
+		return orphans
+	}
+
+	mirrors := map[string]bool{}
+
+	if pods, err := nodePods(); err != nil {
+		failures.add("static-pods", err)
+	} else {
+		for _, pod := range pods {
+			if _, ok := pod.Annotations[annotationMirrorPod]; ok {
+				mirrors[pod.Namespace+"/"+pod.Name] = true
+			}
+		}
+	}
+
+	sources := map[string]string{}
+
+	for _, c := range containers {
+		if source, ok := c.Labels[labelConfigSource]; ok {
+			sources[c.ID] = source
+		}
+	}
+
+	var filtered []types.Container
+
+	for _, c := range orphans {
+
+		source, ok := c.Labels[labelConfigSource]
+		if !ok {
+			source = sources[c.Labels[labelSandboxID]]
+		}
+
+		if source == "file" || source == "http" || mirrors[c.Labels[labelPodNamespace]+"/"+c.Labels[labelPodName]] {
+			continue
+		}
+
+		filtered = append(filtered, c)
+
+	}
+
+	return filtered
+
+}
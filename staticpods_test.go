@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExcludeStaticPods(t *testing.T) {
+
+	savedClient := kubeClient
+	defer func() { kubeClient = savedClient }()
+
+	// The apiserver has no mirror pod for the static pod.
+	kubeClient = fake.NewSimpleClientset()
+
+	staticSandbox, staticApp := testContainer(testID("a1")), testContainer(testID("b2"))
+	staticSandbox.Labels = map[string]string{labelContainerType: containerTypeSandbox, labelConfigSource: "file"}
+	staticApp.Labels = map[string]string{labelSandboxID: staticSandbox.ID}
+
+	apiSandbox, apiApp := testContainer(testID("c3")), testContainer(testID("d4"))
+	apiSandbox.Labels = map[string]string{labelContainerType: containerTypeSandbox, labelConfigSource: "api"}
+	apiApp.Labels = map[string]string{labelSandboxID: apiSandbox.ID}
+
+	containers := []types.Container{staticSandbox, staticApp, apiSandbox, apiApp}
+
+	var failures cycleFailures
+	orphans := excludeStaticPods(containers, containers, &failures)
+
+	if len(failures.list()) != 0 {
+		t.Fatalf("unexpected failures: %v", failures.list())
+	}
+
+	if len(orphans) != 2 || orphans[0].ID != apiSandbox.ID || orphans[1].ID != apiApp.ID {
+		t.Errorf("orphans = %v, want only the containers of the apiserver pod", orphans)
+	}
+
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"expvar"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	docker "github.com/docker/docker/client"
+)
+
+// Internal counters published on /debug/vars alongside the standard memstats and cmdline variables.
+var (
+	cyclesRun        = expvar.NewInt("cycles_run")
+	dockerConnects   = expvar.NewInt("docker_client_connects")
+	dockerConnectErr = expvar.NewInt("docker_client_connect_errors")
+
+	// sightingsTracked is stored by the check loop whenever the sightings change, as the handler serving /debug/vars
+	// must not read the map the loop replaces.
+	sightingsTracked = expvar.NewInt("sightings_tracked")
+)
+
+func init() {
+
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+
+	expvar.Publish("runtime", expvar.Func(func() interface{} {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return map[string]interface{}{
+			"heap_alloc_bytes":  m.HeapAlloc,
+			"heap_inuse_bytes":  m.HeapInuse,
+			"heap_objects":      m.HeapObjects,
+			"num_gc":            m.NumGC,
+			"last_gc_pause_ns":  m.PauseNs[(m.NumGC+255)%256],
+			"total_gc_pause_ns": m.PauseTotalNs,
+		}
+	}))
+
+	// Channel lengths and the pending stops are safe to read from the handler; the sinks are only created at setup,
+	// before the metrics listener starts.
+	expvar.Publish("queue_depths", expvar.Func(func() interface{} {
+		sinks := map[string]int{}
+		for _, s := range allSinks() {
+			sinks[s.id] = len(s.queue.deliveries)
+		}
+		return map[string]interface{}{
+			"events":        len(dispatcher.queue),
+			"sinks":         sinks,
+			"stops_pending": atomic.LoadInt64(&stopsPending),
+		}
+	}))
+
+}
+
+var (
+	dockerMutex  sync.Mutex
+	dockerShared *docker.Client
+)
+
+// sharedDockerClient returns the client of the local Docker daemon shared by the whole process, connecting on first
+// use and counting each (re)connection attempt. A failed connection is attempted again on the next call.
+func sharedDockerClient() (*docker.Client, error) {
+
+	dockerMutex.Lock()
+	defer dockerMutex.Unlock()
+
+	if dockerShared != nil {
+		return dockerShared, nil
+	}
+
+	dockerConnects.Add(1)
+
+	cli, err := docker.NewEnvClient()
+
+	if err != nil {
+		dockerConnectErr.Add(1)
+		return nil, err
+	}
+
+	dockerShared = cli
+	return cli, nil
+
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// agentStatus is the document served on /status.
+type agentStatus struct {
+	Node      string `json:"node"`
+	Mode      string `json:"mode"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+
+	LastCycleStarted  time.Time `json:"lastCycleStarted,omitempty"`
+	LastCycleDuration string    `json:"lastCycleDuration,omitempty"`
+	LastOrphanCount   int       `json:"lastOrphanCount"`
+
+	CircuitBreaker breakerStatus `json:"circuitBreaker"`
+}
+
+var (
+	statusMutex sync.Mutex
+	status      agentStatus
+)
+
+// recordCycleStatus stores the outcome of the latest cycle for /status.
+func recordCycleStatus(started time.Time, elapsed time.Duration, orphans int) {
+
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	status.LastCycleStarted = started
+	status.LastCycleDuration = elapsed.String()
+	status.LastOrphanCount = orphans
+
+}
+
+// serveStatus writes the current agent status as JSON.
+func serveStatus(w http.ResponseWriter, r *http.Request) {
+
+	statusMutex.Lock()
+	current := status
+	statusMutex.Unlock()
+
+	current.Node = nodeFlag
+	current.Mode = modeFlag
+	current.Version = version
+	current.Commit = commit
+	current.BuildDate = buildDate
+	current.GoVersion = runtime.Version()
+	current.CircuitBreaker = breaker.snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(current)
+
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	stopKills = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "stop_kills_total",
+		Help:      "Number of orphans killed because they kept running through every stop attempt.",
+	})
+
+	stopFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "stop_failures_total",
+		Help:      "Number of orphans that could not be stopped.",
+	})
+
+	alreadyDown = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dcc",
+		Name:      "already_down_total",
+		Help:      "Number of orphans not stopped because they had exited, were being removed or were gone by then.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(stopKills, stopFailures, alreadyDown)
+}
+
+// stopContainer stops the container and confirms that it no longer runs, retrying with exponential backoff as set by
+// stop_retry. A container still running after the last attempt is killed when kill_on_stop_failure is set. The
+// error says why the container may still be running.
+func stopContainer(ctx context.Context, cli *docker.Client, id string, timeout time.Duration) error {
+
+	err := withRetry(ctx, config.Timing.StopRetry, retryAlways, "Stopping container "+id, func() error {
+
+		stopCtx, cancel := dockerContext(ctx, timeout)
+		started := time.Now()
+		err := cli.ContainerStop(stopCtx, id, &timeout)
+		observeCall("docker", "ContainerStop", started, err)
+		cancel()
+
+		return confirmStopped(ctx, cli, id, err)
+
+	})
+
+	if err == nil || !config.Timing.KillOnStopFailure || ctx.Err() != nil {
+		return err
+	}
+
+	log.Println("Killing container", id, "after failed stops:", err.Error())
+
+	killCtx, cancel := dockerContext(ctx, 0)
+	started := time.Now()
+	killErr := cli.ContainerKill(killCtx, id, "SIGKILL")
+	observeCall("docker", "ContainerKill", started, killErr)
+	cancel()
+
+	if killErr = confirmStopped(ctx, cli, id, killErr); killErr != nil {
+		return fmt.Errorf("%s; kill failed too: %s", err.Error(), killErr.Error())
+	}
+
+	stopKills.Inc()
+	return nil
+
+}
+
+// confirmStopped inspects the container after a stop or kill and returns nil once it no longer runs or is gone, no
+// matter what the call returned. Otherwise it returns the call's error, or one saying the container still runs.
+func confirmStopped(ctx context.Context, cli *docker.Client, id string, callErr error) error {
+
+	ctx, cancel := dockerContext(ctx, 0)
+	defer cancel()
+
+	started := time.Now()
+	inspect, err := cli.ContainerInspect(ctx, id)
+	observeCall("docker", "ContainerInspect", started, err)
+
+	switch {
+	case docker.IsErrContainerNotFound(err):
+		return nil
+	case err != nil:
+		if callErr != nil {
+			return callErr
+		}
+		return fmt.Errorf("cannot confirm the container stopped: %s", err.Error())
+	case inspect.ContainerJSONBase != nil && inspect.State != nil && !inspect.State.Running:
+		return nil
+	case callErr != nil:
+		return callErr
+	default:
+		return fmt.Errorf("container still running")
+	}
+
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	dccruntime "github.com/kernelpanek/dcc/pkg/runtime"
+	"golang.org/x/net/context"
+)
+
+// plannedStop is an orphan cleared for stopping, with what its outcome is reported with, and the outcome once the
+// stop has run.
+type plannedStop struct {
+	c                          types.Container
+	owner                      podOwner
+	rule, eventReason, subject string
+	from, logTail              string
+
+	snapshot    string
+	err         error
+	skipped     bool
+	deferReason string
+}
+
+// stopPool stops orphans with up to stop_concurrency stops at a time, so a node with hundreds of orphans is not
+// held up by every stop's timeout in turn. Only the stops run in parallel: the guards, the re-verification and the
+// budget's pacing still run one orphan at a time before an orphan is submitted, and the outcomes are reported one at
+// a time once every stop is done.
+type stopPool struct {
+	ctx   context.Context
+	rt    dccruntime.Runtime
+	cli   *docker.Client
+	queue chan *plannedStop
+	wg    sync.WaitGroup
+	stops []*plannedStop
+}
+
+// stopsPending counts the stops submitted to a pool and not yet done, for /debug/vars.
+var stopsPending int64
+
+// newStopPool starts the workers of a cycle's stops.
+func newStopPool(ctx context.Context, rt dccruntime.Runtime, cli *docker.Client) *stopPool {
+
+	workers := config.Timing.StopConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &stopPool{ctx: ctx, rt: rt, cli: cli, queue: make(chan *plannedStop)}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	return p
+
+}
+
+// work runs stops until the queue is closed.
+func (p *stopPool) work() {
+
+	defer p.wg.Done()
+
+	for s := range p.queue {
+		p.run(s)
+		atomic.AddInt64(&stopsPending, -1)
+	}
+
+}
+
+// run runs a stop with its pre and post hooks. A stop not started by the time dcc shuts down, the cycle times out or
+// this instance loses the leader lease is skipped and left for the next cycle.
+func (p *stopPool) run(s *plannedStop) {
+
+	if isShuttingDown() || p.ctx.Err() != nil || !isLeader() {
+		s.skipped = true
+		releaseToController(s.c.ID)
+		return
+	}
+
+	f := newFinding(s.c, s.owner, s.rule, "", s.logTail)
+
+	if s.deferReason = runPreHooks(p.ctx, f); s.deferReason != "" {
+		releaseToController(s.c.ID)
+		return
+	}
+
+	log.Println("Stopping container:", s.c.ID, "(", s.c.Image, ")", "Pod:", s.owner, "State:", s.owner.State)
+	s.snapshot = snapshotContainer(p.ctx, p.cli, s.c)
+	s.err = p.rt.Stop(p.ctx, s.c.ID, s.owner.stopTimeout())
+	heartbeat()
+
+	f.Outcome, f.Snapshot = outcomeStopped, s.snapshot
+	if s.err != nil {
+		f.Outcome, f.Reason = outcomeFailed, s.err.Error()
+	}
+
+	// The controller's reservation is settled as each stop is done, so the authorizations of the orphans still
+	// queued see a failed stop's slot free again.
+	if s.err == nil {
+		confirmToController(f)
+	} else {
+		releaseToController(s.c.ID)
+	}
+
+	runPostHooks(p.ctx, f)
+
+}
+
+// submit hands a stop to the workers, waiting for one to be free.
+func (p *stopPool) submit(s *plannedStop) {
+	p.stops = append(p.stops, s)
+	atomic.AddInt64(&stopsPending, 1)
+	p.queue <- s
+}
+
+// wait waits for every submitted stop and returns them in the order they were submitted.
+func (p *stopPool) wait() []*plannedStop {
+	close(p.queue)
+	p.wg.Wait()
+	return p.stops
+}
+
+// reportStop records the outcome of a stop in events, the stop failure cooldown and the DanglingContainer resource,
+// and returns its finding. Skipped stops have no finding.
+func reportStop(s *plannedStop, failures *cycleFailures) (finding, bool) {
+
+	c, owner := s.c, s.owner
+
+	if s.skipped {
+		log.Println("Leaving container for the next cycle:", c.ID, "(", c.Image, ")")
+		return finding{}, false
+	}
+
+	if s.deferReason != "" {
+		return reportDeferred(s, s.deferReason), true
+	}
+
+	if s.err != nil {
+
+		log.Println("Failed to stop container:", c.ID, "(", c.Image, ")", s.err.Error())
+		stopFailures.Inc()
+		failures.add("container-stop", fmt.Errorf("%s: %s", c.ID, s.err.Error()))
+		escalate := recordStopFailure(c.ID)
+		nodeOrchestrator.Event(dccEvent{
+			Reason:      s.eventReason + "StopFailed",
+			Message:     fmt.Sprintf("%s could not be stopped: %s (%s)%s: %s", s.subject, c.ID, c.ImageID, s.from, s.err.Error()),
+			Annotations: containerAnnotations(c, owner, s.rule, outcomeFailed),
+			Object:      eventObjectFor(owner),
+		})
+		f := newFinding(c, owner, s.rule, outcomeFailed, s.logTail)
+		f.Reason = s.err.Error()
+		f.Snapshot = s.snapshot
+		f.Escalated = escalate
+		recordDanglingContainer(f, s.err)
+		return f, true
+
+	}
+
+	nodeOrchestrator.Event(dccEvent{
+		Reason:      s.eventReason,
+		Message:     fmt.Sprintf("%s stopped: %s (%s)%s", s.subject, c.ID, c.ImageID, s.from),
+		Annotations: containerAnnotations(c, owner, s.rule, outcomeStopped),
+		Object:      eventObjectFor(owner),
+	})
+	clearStopCooldown(c.ID)
+	f := newFinding(c, owner, s.rule, outcomeStopped, s.logTail)
+	f.Snapshot = s.snapshot
+	recordDanglingContainer(f, nil)
+	return f, true
+
+}
+
+// reportDeferred records an orphan left running for the given reason in events and the DanglingContainer resource,
+// and returns its finding.
+func reportDeferred(s *plannedStop, reason string) finding {
+
+	c, owner := s.c, s.owner
+
+	log.Println("Deferring container:", c.ID, "(", c.Image, ")", "Reason:", reason)
+	annotations := containerAnnotations(c, owner, s.rule, outcomeDeferred)
+	annotations[annotationPrefix+"defer-reason"] = reason
+	nodeOrchestrator.Event(dccEvent{
+		Reason:      s.eventReason,
+		Message:     fmt.Sprintf("%s left running: %s (%s)%s: %s", s.subject, c.ID, c.ImageID, s.from, reason),
+		Annotations: annotations,
+		Object:      eventObjectFor(owner),
+	})
+	f := newFinding(c, owner, s.rule, outcomeDeferred, s.logTail)
+	f.Reason = reason
+	recordDanglingContainer(f, nil)
+	return f
+
+}
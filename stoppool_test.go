@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestStopPool(t *testing.T) {
+
+	stops, fails := testID("a1"), testID("b2")
+
+	rt := newFakeRuntime(testContainer(stops), testContainer(fails))
+	rt.stopErrs[fails] = errors.New("device or resource busy")
+	orch := &fakeOrchestrator{}
+	defer useFakes(rt, orch)()
+
+	config.Timing.StopConcurrency = 2
+
+	pool := newStopPool(context.Background(), rt, nil)
+
+	for _, id := range []string{stops, fails} {
+		pool.submit(&plannedStop{c: testContainer(id), rule: ruleUnknownToKubernetes, eventReason: "DanglingContainer", subject: "Dangling container"})
+	}
+
+	var failures cycleFailures
+	outcomes := map[string]string{}
+
+	for _, s := range pool.wait() {
+		if f, ok := reportStop(s, &failures); ok {
+			outcomes[f.ContainerID] = f.Outcome
+		}
+	}
+
+	if outcomes[stops] != outcomeStopped || outcomes[fails] != outcomeFailed {
+		t.Errorf("outcomes = %v, want %s stopped and %s failed", outcomes, stops, fails)
+	}
+
+	if containers, _ := rt.List(context.Background()); len(containers) != 1 || containers[0].ID != fails {
+		t.Errorf("running after the stops: %v, want only %s", containers, fails)
+	}
+
+	if !failures.failed("container-stop") {
+		t.Error("failed stop not recorded as a cycle failure")
+	}
+
+	if reason := cooldownGuard(testContainer(fails), podOwner{}); reason == "" {
+		t.Error("container that failed to stop is not on cooldown")
+	}
+
+	// A stopped container can be removed; one still running cannot, as with Docker.
+	if err := rt.Remove(context.Background(), stops); err != nil {
+		t.Errorf("Remove of a stopped container: %v", err)
+	}
+
+	if err := rt.Remove(context.Background(), fails); err == nil {
+		t.Error("Remove of a running container succeeded")
+	}
+
+	if len(orch.reasons()) != 2 {
+		t.Errorf("events = %v, want one per stop", orch.reasons())
+	}
+
+}
+
+func TestStopPoolShutdown(t *testing.T) {
+
+	id := testID("a1")
+
+	rt := newFakeRuntime(testContainer(id))
+	defer useFakes(rt, &fakeOrchestrator{})()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pool := newStopPool(ctx, rt, nil)
+	pool.submit(&plannedStop{c: testContainer(id)})
+
+	var failures cycleFailures
+
+	for _, s := range pool.wait() {
+		if _, ok := reportStop(s, &failures); ok {
+			t.Error("stop of a cancelled cycle was reported")
+		}
+	}
+
+	if containers, _ := rt.List(context.Background()); len(containers) != 1 {
+		t.Error("container stopped after the cycle was cancelled")
+	}
+
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	summaryRetention   = 7 * 24 * time.Hour
+	summaryMaxActions  = 20
+	summaryMinInterval = 10 * time.Minute
+)
+
+// summaryBucket counts the findings of one hour.
+type summaryBucket struct {
+	Hour    time.Time      `json:"hour"`
+	Found   int            `json:"found"`
+	Removed int            `json:"removed"`
+	Images  map[string]int `json:"images"`
+}
+
+// summaryTotals aggregates the buckets of a window.
+type summaryTotals struct {
+	Found     int          `json:"found"`
+	Removed   int          `json:"removed"`
+	TopImages []imageCount `json:"topImages"`
+}
+
+// nodeSummary is the rolling summary written to the ConfigMap.
+type nodeSummary struct {
+	Node        string        `json:"node"`
+	Updated     time.Time     `json:"updated"`
+	Last24h     summaryTotals `json:"last24h"`
+	Last7d      summaryTotals `json:"last7d"`
+	LastActions []finding     `json:"lastActions"`
+}
+
+var (
+	summaryBuckets []summaryBucket
+	summaryActions []finding
+	summaryWritten time.Time
+	summaryLoaded  bool
+)
+
+// summaryConfigMapName returns the name of the node's summary ConfigMap.
+func summaryConfigMapName() string {
+	if config.Summary.ConfigMapName != "" {
+		return config.Summary.ConfigMapName
+	}
+	return "dcc-summary-" + nodeFlag
+}
+
+// updateSummary adds the cycle to the rolling summary and writes it to the node's ConfigMap. Quiet cycles only
+// refresh the ConfigMap every few minutes to keep apiserver writes down.
+func updateSummary(report cycleReport) {
+
+	if config.Summary.ConfigMapNamespace == "" {
+		return
+	}
+
+	if !summaryLoaded {
+		loadSummary()
+	}
+
+	if report.Found() > 0 {
+		addToSummary(report)
+	} else if time.Since(summaryWritten) < summaryMinInterval {
+		return
+	}
+
+	pruneSummary(report.Time)
+
+	if err := writeSummary(report.Time); err != nil {
+		log.Println("Cannot write summary ConfigMap:", err.Error())
+		return
+	}
+
+	summaryWritten = report.Time
+
+}
+
+// loadSummary restores the hourly buckets and last actions kept in an existing ConfigMap, so restarts don't lose
+// the history.
+func loadSummary() {
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(config.Summary.ConfigMapNamespace).Get(summaryConfigMapName(), metav1.GetOptions{})
+
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Println("Cannot read summary ConfigMap:", err.Error())
+			return
+		}
+		summaryLoaded = true
+		return
+	}
+
+	if data, ok := cm.Data["buckets.json"]; ok {
+		if err := json.Unmarshal([]byte(data), &summaryBuckets); err != nil {
+			log.Println("Ignoring unreadable summary buckets:", err.Error())
+		}
+	}
+
+	var previous nodeSummary
+	if data, ok := cm.Data["summary.json"]; ok && json.Unmarshal([]byte(data), &previous) == nil {
+		summaryActions = previous.LastActions
+	}
+
+	summaryLoaded = true
+
+}
+
+// addToSummary counts the report's findings in the bucket of the current hour.
+func addToSummary(report cycleReport) {
+
+	hour := report.Time.Truncate(time.Hour)
+
+	if len(summaryBuckets) == 0 || !summaryBuckets[len(summaryBuckets)-1].Hour.Equal(hour) {
+		summaryBuckets = append(summaryBuckets, summaryBucket{Hour: hour, Images: map[string]int{}})
+	}
+
+	bucket := &summaryBuckets[len(summaryBuckets)-1]
+	bucket.Found += report.Found()
+	bucket.Removed += report.Removed()
+
+	for _, f := range report.Findings {
+		bucket.Images[f.Image]++
+	}
+
+	summaryActions = append(summaryActions, report.Findings...)
+	if len(summaryActions) > summaryMaxActions {
+		summaryActions = summaryActions[len(summaryActions)-summaryMaxActions:]
+	}
+
+}
+
+// pruneSummary drops buckets older than the retention window.
+func pruneSummary(now time.Time) {
+
+	cutoff := now.Add(-summaryRetention)
+
+	for len(summaryBuckets) > 0 && summaryBuckets[0].Hour.Before(cutoff) {
+		summaryBuckets = summaryBuckets[1:]
+	}
+
+}
+
+// totalsSince aggregates the buckets starting at or after since.
+func totalsSince(since time.Time) summaryTotals {
+
+	var totals summaryTotals
+	images := map[string]int{}
+
+	for _, b := range summaryBuckets {
+
+		if b.Hour.Before(since.Truncate(time.Hour)) {
+			continue
+		}
+
+		totals.Found += b.Found
+		totals.Removed += b.Removed
+
+		for image, count := range b.Images {
+			images[image] += count
+		}
+
+	}
+
+	for image, count := range images {
+		totals.TopImages = append(totals.TopImages, imageCount{Image: image, Count: count})
+	}
+
+	sort.Slice(totals.TopImages, func(i, j int) bool {
+		return totals.TopImages[i].Count > totals.TopImages[j].Count
+	})
+
+	if len(totals.TopImages) > maxTopImages {
+		totals.TopImages = totals.TopImages[:maxTopImages]
+	}
+
+	return totals
+
+}
+
+// writeSummary creates or updates the node's summary ConfigMap.
+func writeSummary(now time.Time) error {
+
+	summary := nodeSummary{
+		Node:        nodeFlag,
+		Updated:     now,
+		Last24h:     totalsSince(now.Add(-24 * time.Hour)),
+		Last7d:      totalsSince(now.Add(-summaryRetention)),
+		LastActions: summaryActions,
+	}
+
+	summaryJSON, err := json.MarshalIndent(summary, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	bucketsJSON, err := json.Marshal(summaryBuckets)
+
+	if err != nil {
+		return err
+	}
+
+	configMaps := kubeClient.CoreV1().ConfigMaps(config.Summary.ConfigMapNamespace)
+
+	cm, err := configMaps.Get(summaryConfigMapName(), metav1.GetOptions{})
+
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      summaryConfigMapName(),
+			Namespace: config.Summary.ConfigMapNamespace,
+			Labels:    map[string]string{"app": "dcc", "dcc.kernelpanek.io/node": nodeFlag},
+		}}
+	} else if err != nil {
+		return err
+	}
+
+	cm.Data = map[string]string{
+		"summary.json": string(summaryJSON),
+		"buckets.json": string(bucketsJSON),
+	}
+
+	if cm.ResourceVersion == "" {
+		_, err = configMaps.Create(cm)
+	} else {
+		_, err = configMaps.Update(cm)
+	}
+
+	return err
+
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// uncleanTaint keeps new workloads off a node polluted by dangling containers.
+var uncleanTaint = v1.Taint{Key: annotationPrefix + "unclean", Value: "true", Effect: v1.TaintEffectNoSchedule}
+
+// overTaintLimits returns true if the orphans left after the cycle exceed the configured count or writable layer
+// size. Sizes are only known when collect_sizes is enabled.
+func overTaintLimits(report cycleReport) bool {
+
+	limits := config.NodeStatus.Taint
+	remaining := 0
+	var size int64
+
+	for _, f := range report.Findings {
+		if f.Outcome != outcomeStopped {
+			remaining++
+			size += f.SizeBytes
+		}
+	}
+
+	if limits.MaxOrphans > 0 && remaining > limits.MaxOrphans {
+		return true
+	}
+
+	return limits.MaxSizeMB > 0 && size > limits.MaxSizeMB*1024*1024
+
+}
+
+// taintNode adds the unclean taint while the orphans on the node exceed the limits and removes it once cleanup has
+// brought them back under.
+func taintNode(report cycleReport) {
+
+	limits := config.NodeStatus.Taint
+
+	if limits.MaxOrphans < 1 && limits.MaxSizeMB < 1 {
+		return
+	}
+
+	started := time.Now()
+	node, err := kubeClient.CoreV1().Nodes().Get(nodeFlag, metav1.GetOptions{})
+	observeCall("kubernetes", "GetNode", started, err)
+
+	if err != nil {
+		log.Println("Cannot read node taints:", err.Error())
+		return
+	}
+
+	want := overTaintLimits(report)
+	var taints []v1.Taint
+	has := false
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == uncleanTaint.Key && taint.Effect == uncleanTaint.Effect {
+			has = true
+			continue
+		}
+		taints = append(taints, taint)
+	}
+
+	if want == has {
+		return
+	}
+
+	if want {
+		taints = append(taints, uncleanTaint)
+		log.Println("Tainting node:", uncleanTaint.ToString())
+	} else {
+		log.Println("Removing node taint:", uncleanTaint.ToString())
+	}
+
+	node.Spec.Taints = taints
+
+	// Taints are a plain list in the node spec, so they are replaced as a whole under the node's resourceVersion; a
+	// conflicting update is retried by the next cycle.
+	started = time.Now()
+	_, err = kubeClient.CoreV1().Nodes().Update(node)
+	observeCall("kubernetes", "UpdateNode", started, err)
+
+	if err != nil {
+		log.Println("Cannot update node taints:", err.Error())
+	}
+
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// dockerLimiter limits the rate of calls to the Docker daemon across the process, when configured.
+var dockerLimiter flowcontrol.RateLimiter
+
+// setupDockerLimits creates the rate limiter shared by all Docker calls. The Kubernetes clients are limited by
+// client-go itself.
+func setupDockerLimits() {
+
+	if config.Docker.QPS > 0 {
+		dockerLimiter = flowcontrol.NewTokenBucketRateLimiter(config.Docker.QPS, config.Docker.Burst)
+	}
+
+}
+
+// cycleContext returns the context of a check cycle, which ends after the cycle timeout. Every Docker call of the
+// cycle runs within it, so a hung daemon costs at most one cycle.
+func cycleContext() (context.Context, context.CancelFunc) {
+
+	if timeout := config.Timeouts.Cycle; timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	}
+
+	return context.WithCancel(context.Background())
+
+}
+
+// dockerContext bounds a single Docker call to the docker timeout, plus extra for calls that wait on purpose, such as
+// a stop waiting for the container to exit. Every Docker call is made through it, so it also waits for the call's
+// turn under the Docker rate limit.
+func dockerContext(parent context.Context, extra time.Duration) (context.Context, context.CancelFunc) {
+
+	if dockerLimiter != nil {
+		dockerLimiter.Accept()
+	}
+
+	if timeout := config.Timeouts.Docker; timeout > 0 {
+		return context.WithTimeout(parent, time.Duration(timeout)*time.Second+extra)
+	}
+
+	return context.WithCancel(parent)
+
+}
+
+// timedOut returns true if the call failed for running out of time: a Docker call past its context's deadline, or a
+// Kubernetes call cut off by the client's timeout.
+func timedOut(err error) bool {
+
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+
+}
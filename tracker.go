@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// sighting tracks a container that has been classified as an orphan in consecutive cycles.
+type sighting struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Namespace string    `json:"namespace,omitempty"`
+}
+
+var (
+	sightings       = map[string]*sighting{}
+	sightingsLoaded bool
+)
+
+// recordSightings updates the sighting counts with the orphans found in this cycle. Containers that are no longer
+// orphaned are forgotten, so a count always reflects consecutive cycles. A cycle scoped to some namespaces leaves
+// the sightings of other containers alone.
+func recordSightings(orphans []types.Container, namespaces []string) {
+
+	if !sightingsLoaded {
+		loadSightings()
+	}
+
+	now := time.Now()
+	current := make(map[string]*sighting, len(orphans))
+
+	if len(namespaces) > 0 {
+		scoped := map[string]bool{}
+		for _, namespace := range namespaces {
+			scoped[namespace] = true
+		}
+		for id, s := range sightings {
+			if !scoped[s.Namespace] {
+				current[id] = s
+			}
+		}
+	}
+
+	for _, c := range orphans {
+
+		s, ok := sightings[c.ID]
+
+		if !ok {
+			s = &sighting{FirstSeen: now, Namespace: c.Labels[labelPodNamespace]}
+		}
+
+		s.LastSeen = now
+		s.Count++
+		current[c.ID] = s
+
+	}
+
+	sightings = current
+	sightingsTracked.Set(int64(len(sightings)))
+
+	saveSightings()
+
+}
+
+// loadSightings restores the sightings kept in the state file, so a restart does not reset the count of consecutive
+// cycles a container has been orphaned for.
+func loadSightings() {
+
+	sightingsLoaded = true
+	path := config.Sanity.StateFile
+
+	if path == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("Cannot read sightings state:", err.Error())
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &sightings); err != nil {
+		log.Println("Ignoring unreadable sightings state:", err.Error())
+		sightings = map[string]*sighting{}
+	}
+
+}
+
+// saveSightings writes the sightings to the state file. The file is replaced by a rename so a crash mid-write
+// cannot leave it truncated.
+func saveSightings() {
+
+	path := config.Sanity.StateFile
+
+	if path == "" {
+		return
+	}
+
+	data, err := json.Marshal(sightings)
+
+	if err != nil {
+		log.Println("Cannot encode sightings state:", err.Error())
+		return
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		log.Println("Cannot write sightings state:", err.Error())
+		return
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println("Cannot write sightings state:", err.Error())
+	}
+
+}
+
+// sightingGuard defers removing a container until it has been found orphaned in min_sightings consecutive cycles,
+// riding out a stale apiserver or a pod status that has not caught up with the runtime yet.
+func sightingGuard(c types.Container, owner podOwner) string {
+
+	required := config.Sanity.MinSightings
+
+	count := 0
+	if s, ok := sightings[c.ID]; ok {
+		count = s.Count
+	}
+
+	if count >= required {
+		return ""
+	}
+
+	return fmt.Sprintf("orphaned in %d of %d required consecutive cycles", count, required)
+
+}
+
+// shouldLogSighting returns true when a repeated finding is due to be logged: on first detection and then every
+// sample_every cycles. Remove mode always logs since a repeat there means the previous stop did not take.
+func shouldLogSighting(id string) bool {
+
+	s, ok := sightings[id]
+	every := config.Logging.SampleEvery
+
+	if !ok || modeFlag == "remove" || every <= 1 {
+		return true
+	}
+
+	return (s.Count-1)%every == 0
+
+}
+
+// logOrphans logs the orphans found in this cycle, sampling repeated findings.
+func logOrphans(orphans []types.Container) {
+
+	for _, c := range orphans {
+
+		if !shouldLogSighting(c.ID) {
+			continue
+		}
+
+		var count int
+		if s, ok := sightings[c.ID]; ok {
+			count = s.Count
+		}
+
+		pod := ""
+		if name := c.Labels[labelPodName]; name != "" {
+			pod = c.Labels[labelPodNamespace] + "/" + name
+		}
+
+		log.Println("Orphan Container Found:", c.ID, "(", c.Image, ") Pod:", pod, "Age:", time.Since(time.Unix(c.Created, 0)), "Seen:", count, "times")
+
+	}
+
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	dccconfig "github.com/kernelpanek/dcc/pkg/config"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+)
+
+// objectStore stores report objects in a bucket.
+type objectStore interface {
+	Put(key string, body []byte, contentType string) error
+}
+
+// s3Store uploads to S3 or an S3 compatible endpoint. Credentials come from the default AWS chain, which covers IRSA
+// web identity tokens as well as keys provided through the environment from a Secret.
+type s3Store struct {
+	bucket   string
+	uploader *s3manager.Uploader
+}
+
+func newS3Store(cfg dccconfig.Upload) (*s3Store, error) {
+
+	awsConfig := &aws.Config{Region: aws.String(cfg.Region)}
+
+	if cfg.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Store{bucket: cfg.Bucket, uploader: s3manager.NewUploader(sess)}, nil
+
+}
+
+func (s *s3Store) Put(key string, body []byte, contentType string) error {
+	_, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// gcsStore uploads to Google Cloud Storage using workload identity, or a service account key file from a Secret.
+type gcsStore struct {
+	bucket *storage.BucketHandle
+}
+
+func newGCSStore(cfg dccconfig.Upload) (*gcsStore, error) {
+
+	var opts []option.ClientOption
+
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+
+	client, err := storage.NewClient(context.Background(), opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcsStore{bucket: client.Bucket(cfg.Bucket)}, nil
+
+}
+
+func (g *gcsStore) Put(key string, body []byte, contentType string) error {
+
+	w := g.bucket.Object(key).NewWriter(context.Background())
+	w.ContentType = contentType
+
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+
+}
+
+// azureStore uploads to an Azure Blob Storage container with the storage account key read from a Secret.
+type azureStore struct {
+	container azblob.ContainerURL
+}
+
+func newAzureStore(cfg dccconfig.Upload) (*azureStore, error) {
+
+	key, err := readSecret(cfg.AzureKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := azblob.NewSharedKeyCredential(cfg.AzureAccount, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", cfg.AzureAccount, cfg.Bucket))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureStore{container: azblob.NewContainerURL(*u, azblob.NewPipeline(credential, azblob.PipelineOptions{}))}, nil
+
+}
+
+func (a *azureStore) Put(key string, body []byte, contentType string) error {
+	_, err := azblob.UploadBufferToBlockBlob(context.Background(), body, a.container.NewBlockBlobURL(key),
+		azblob.UploadToBlockBlobOptions{BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType}})
+	return err
+}
+
+var (
+	reportStore   objectStore
+	dailyReports  []cycleReport
+	dailyReportOn string
+)
+
+// setupReportUpload connects to the configured object storage provider.
+func setupReportUpload() {
+
+	var err error
+	cfg := config.Reports.Upload
+
+	switch cfg.Provider {
+	case "":
+		return
+	case "s3":
+		reportStore, err = newS3Store(cfg)
+	case "gcs":
+		reportStore, err = newGCSStore(cfg)
+	case "azure":
+		reportStore, err = newAzureStore(cfg)
+	default:
+		err = fmt.Errorf("unknown provider %q", cfg.Provider)
+	}
+
+	if err != nil {
+		log.Println("Report upload disabled:", err.Error())
+		reportStore = nil
+	}
+
+}
+
+// archiveReport uploads the cycle report. Per-cycle uploads skip cycles without findings; daily uploads collect
+// every cycle and upload the day, clean or not, once the date changes.
+func archiveReport(report cycleReport) {
+
+	if reportStore == nil {
+		return
+	}
+
+	if config.Reports.Upload.Period != "daily" {
+		if report.Found() > 0 {
+			uploadReports(report.Time.UTC().Format("2006-01-02T15-04-05Z"), []cycleReport{report})
+		}
+		return
+	}
+
+	day := report.Time.UTC().Format("2006-01-02")
+
+	if dailyReportOn != "" && day != dailyReportOn {
+		uploadReports(dailyReportOn, dailyReports)
+		dailyReports = nil
+	}
+
+	dailyReportOn = day
+	dailyReports = append(dailyReports, report)
+
+}
+
+// uploadReports encodes the reports in the configured format and uploads them as a single object.
+func uploadReports(name string, reports []cycleReport) {
+
+	var body []byte
+	var err error
+	contentType := "application/json"
+	extension := "json"
+
+	if config.Reports.Upload.Format == "csv" {
+		body, err = reportsCSV(reports)
+		contentType = "text/csv"
+		extension = "csv"
+	} else {
+		body, err = json.Marshal(reports)
+	}
+
+	if err != nil {
+		log.Println("Cannot encode report:", err.Error())
+		return
+	}
+
+	key := fmt.Sprintf("%s%s/%s.%s", config.Reports.Upload.Prefix, nodeFlag, name, extension)
+
+	started := time.Now()
+	err = reportStore.Put(key, body, contentType)
+	observeCall(config.Reports.Upload.Provider, "PutObject", started, err)
+
+	if err != nil {
+		log.Println("Report upload failed:", err.Error())
+		return
+	}
+
+	log.Println("Report uploaded:", key)
+
+}
+
+// reportsCSV flattens the findings of the reports into one CSV row each.
+func reportsCSV(reports []cycleReport) ([]byte, error) {
+
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+
+	w.Write([]string{"node", "cycle_time", "container_id", "image", "image_id", "created", "size_bytes", "rule", "outcome"})
+
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			w.Write([]string{
+				r.Node,
+				r.Time.UTC().Format(time.RFC3339),
+				f.ContainerID,
+				f.Image,
+				f.ImageID,
+				f.Created.UTC().Format(time.RFC3339),
+				strconv.FormatInt(f.SizeBytes, 10),
+				f.Rule,
+				f.Outcome,
+			})
+		}
+	}
+
+	w.Flush()
+	return buffer.Bytes(), w.Error()
+
+}
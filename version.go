@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Build information, set at link time with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+var buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "dcc",
+	Name:      "build_info",
+	Help:      "Build information of the running binary; the value is always 1.",
+}, []string{"version", "commit", "build_date", "go_version"})
+
+func init() {
+	metrics.Registry.MustRegister(buildInfo)
+	buildInfo.WithLabelValues(version, commit, buildDate, runtime.Version()).Set(1)
+}
+
+// versionString returns the build information in a single human-readable line.
+func versionString() string {
+	return fmt.Sprintf("dcc %s (commit %s, built %s, %s)", version, commit, buildDate, runtime.Version())
+}
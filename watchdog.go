@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// lastHeartbeat is the unix time in nanoseconds at which the check loop last showed signs of life.
+var lastHeartbeat int64
+
+// heartbeat marks the check loop as alive.
+func heartbeat() {
+	atomic.StoreInt64(&lastHeartbeat, time.Now().UnixNano())
+}
+
+// residentMemory returns the process RSS in bytes, falling back to the memory obtained from the OS by the Go runtime
+// when /proc is not available.
+func residentMemory() uint64 {
+
+	if data, err := ioutil.ReadFile("/proc/self/statm"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) > 1 {
+			if pages, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				return pages * uint64(os.Getpagesize())
+			}
+		}
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Sys
+
+}
+
+// watchdogBreaches returns a description of every configured limit dcc is currently exceeding.
+func watchdogBreaches() []string {
+
+	var breaches []string
+
+	if limit := config.Watchdog.MaxRSSMB; limit > 0 {
+		if rss := residentMemory() / 1024 / 1024; rss > limit {
+			breaches = append(breaches, fmt.Sprintf("RSS %dMB exceeds %dMB", rss, limit))
+		}
+	}
+
+	if limit := config.Watchdog.MaxGoroutines; limit > 0 {
+		if n := runtime.NumGoroutine(); n > limit {
+			breaches = append(breaches, fmt.Sprintf("%d goroutines exceed %d", n, limit))
+		}
+	}
+
+	if limit, _ := loopStallLimit(); limit > 0 {
+		if stalled := time.Since(time.Unix(0, atomic.LoadInt64(&lastHeartbeat))); stalled > limit {
+			breaches = append(breaches, fmt.Sprintf("check loop silent for %s, longer than %s", stalled.Round(time.Second), limit))
+		}
+	}
+
+	return breaches
+
+}
+
+// loopStallLimit returns how long the check loop may stay silent before the watchdog calls it stalled, or 0 when the
+// check is disabled. Between heartbeats the loop may run a cycle of up to the cycle timeout and then wait a whole
+// check interval, so a max_loop_stall shorter than that would count every healthy cycle as a stall, and with
+// exit_on_breach crash-loop dcc. Such a limit is raised to twice the check interval plus the cycle timeout, and
+// raised is set.
+func loopStallLimit() (limit time.Duration, raised bool) {
+
+	limit = time.Duration(config.Watchdog.MaxLoopStall) * time.Second
+
+	if limit == 0 {
+		return 0, false
+	}
+
+	interval := time.Duration(config.Timing.CheckInterval) * time.Second
+	cycle := time.Duration(config.Timeouts.Cycle) * time.Second
+
+	if limit < interval+cycle {
+		return 2*interval + cycle, true
+	}
+
+	return limit, false
+
+}
+
+// dumpDiagnostics logs the goroutine stacks and memory statistics of the process.
+func dumpDiagnostics() {
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	log.Println("Watchdog diagnostics: heap_alloc:", m.HeapAlloc, "heap_inuse:", m.HeapInuse, "sys:", m.Sys, "num_gc:", m.NumGC, "goroutines:", runtime.NumGoroutine())
+
+	var stacks bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+	log.Println("Watchdog goroutine dump:\n" + stacks.String())
+
+}
+
+// runWatchdog periodically checks dcc's own health. On a breach it logs a diagnostic dump, emits an event and, when
+// configured, exits so the kubelet restarts the agent instead of leaving it wedged.
+func runWatchdog() {
+
+	if config.Watchdog.Interval == 0 {
+		return
+	}
+
+	if limit, raised := loopStallLimit(); raised {
+		log.Println("max_loop_stall is shorter than check_interval plus the cycle timeout; using", limit, "instead.")
+	}
+
+	heartbeat()
+	breached := false
+
+	for range time.Tick(time.Duration(config.Watchdog.Interval) * time.Second) {
+
+		breaches := watchdogBreaches()
+
+		if len(breaches) == 0 {
+			breached = false
+			continue
+		}
+
+		if breached && !config.Watchdog.ExitOnBreach {
+			continue
+		}
+
+		breached = true
+		message := "Watchdog breach: " + strings.Join(breaches, "; ")
+		log.Println(message)
+		dumpDiagnostics()
+		sendEvent("WatchdogBreach", message)
+
+		if config.Watchdog.ExitOnBreach {
+			// Give the event broadcaster a moment to deliver the event before exiting.
+			time.Sleep(2 * time.Second)
+			log.Fatalln("Exiting after watchdog breach.")
+		}
+
+	}
+
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// minWhitelistLength is the shortest whitelist entry that is not suspiciously broad.
+const minWhitelistLength = 4
+
+// broadWhitelistPercent is the share of the node's containers above which a single entry is reported as too broad.
+const broadWhitelistPercent = 50
+
+// commonImageParts occur in the names of many unrelated images, so a whitelist entry that is just one of them
+// whitelists far more than intended.
+var commonImageParts = []string{
+	"docker", "library", "latest", "gcr.io", "k8s", "quay.io", "registry", "sha256", "http", "image", "release",
+}
+
+// validateWhitelist drops empty whitelist entries, which match every image, and warns about entries so short or so
+// common that they probably match far more images than intended.
+func validateWhitelist() {
+
+	var valid []string
+
+	for _, image := range config.Whitelist.Images {
+
+		trimmed := strings.TrimSpace(image)
+
+		if trimmed == "" {
+			log.Println("Ignoring empty whitelist entry, which would whitelist every container.")
+			continue
+		}
+
+		if len(trimmed) < minWhitelistLength {
+			log.Println("Whitelist entry", fmt.Sprintf("%q", trimmed), "is very short and may match unrelated images.")
+		}
+
+		for _, part := range commonImageParts {
+			if strings.Contains(part, trimmed) {
+				log.Println("Whitelist entry", fmt.Sprintf("%q", trimmed), "is part of the common image name part", part, "and may match unrelated images.")
+				break
+			}
+		}
+
+		valid = append(valid, trimmed)
+
+	}
+
+	config.Whitelist.Images = valid
+
+}
+
+// reportWhitelistMatches logs how many of the node's running containers each whitelist entry matches, and records a
+// warning event for entries that match most of them, so a typo cannot silently whitelist the whole node.
+func reportWhitelistMatches() {
+
+	if len(config.Whitelist.Images) == 0 {
+		return
+	}
+
+	cli, err := sharedDockerClient()
+
+	var containers []types.Container
+
+	if err == nil {
+		ctx, cancel := dockerContext(context.Background(), 0)
+		started := time.Now()
+		containers, err = cli.ContainerList(ctx, types.ContainerListOptions{})
+		observeCall("docker", "ContainerList", started, err)
+		cancel()
+	}
+
+	if err != nil {
+		log.Println("Cannot list containers to check the whitelist:", err.Error())
+		return
+	}
+
+	if len(containers) == 0 {
+		return
+	}
+
+	for _, image := range config.Whitelist.Images {
+
+		matched := 0
+		for _, c := range containers {
+			if strings.Contains(c.Image, image) {
+				matched++
+			}
+		}
+
+		percent := 100 * matched / len(containers)
+		log.Println("Whitelist entry", fmt.Sprintf("%q", image), "matches", matched, "of", len(containers), "containers.")
+
+		if percent > broadWhitelistPercent {
+			sendEvent("WhitelistTooBroad", fmt.Sprintf("Whitelist entry %q matches %d%% of the containers on the node", image, percent))
+		}
+
+	}
+
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workloadAPIs maps the kinds of intermediate owners to the API path they are served under. Owners of other kinds
+// are top-level workloads.
+var workloadAPIs = map[string]string{
+	"ReplicaSet": "/apis/apps/v1/namespaces/%s/replicasets/%s",
+	"Job":        "/apis/batch/v1/namespaces/%s/jobs/%s",
+}
+
+// maxOwnerDepth bounds the walk up owner references.
+const maxOwnerDepth = 4
+
+// controllerOf returns the controlling owner reference, or the first one if none is marked as controller.
+func controllerOf(refs []metav1.OwnerReference) *metav1.OwnerReference {
+
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+
+	return nil
+
+}
+
+// topLevelWorkload walks the owner references of a pod up to its top-level workload, such as a Deployment through
+// its ReplicaSet or a CronJob through its Job, and returns it as kind/name. The deepest owner that could be read is
+// returned when the walk cannot continue.
+func topLevelWorkload(namespace string, refs []metav1.OwnerReference) string {
+
+	ref := controllerOf(refs)
+	workload := ""
+
+	for depth := 0; ref != nil && depth < maxOwnerDepth; depth++ {
+
+		workload = ref.Kind + "/" + ref.Name
+		path, ok := workloadAPIs[ref.Kind]
+
+		if !ok {
+			break
+		}
+
+		started := time.Now()
+		raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(fmt.Sprintf(path, namespace, ref.Name)).Do().Raw()
+		observeCall("kubernetes", "Get"+ref.Kind, started, err)
+
+		if err != nil {
+			break
+		}
+
+		var object struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+
+		if err := json.Unmarshal(raw, &object); err != nil {
+			break
+		}
+
+		ref = controllerOf(object.Metadata.OwnerReferences)
+
+	}
+
+	return workload
+
+}